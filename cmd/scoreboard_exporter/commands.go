@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/api"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/dashboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/envflag"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fakegameserver"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/notify"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/watch"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// how long fetch waits for the scoreboard, on top of the retries of the
+// HTTP client
+const fetchTimeout = 2 * time.Minute
+
+// how many scoreboard events /api/v1/events keeps
+const recentEvents = 5000
+
+// where fetch and watch print, a variable so that tests can read it
+var stdout io.Writer = os.Stdout
+
+// serve exports a backend's scoreboard until the process is stopped.
+func serve(g *globalFlags, b backend, args []string) int {
+	cleanup, err := metrics.Setup()
+	if err != nil {
+		return runtimeError(err)
+	}
+	defer cleanup()
+
+	stopRecording, err := startRecording(g)
+	if err != nil {
+		return usageError(err)
+	}
+	defer stopRecording()
+
+	if err := b.Init(args); err != nil {
+		return usageError(err)
+	}
+	status.Register(b)
+
+	// with --strict, drift stops the exporter before it serves wrong numbers
+	if *g.strict {
+		if err := checkSchema(b); err != nil {
+			return usageError(err)
+		}
+	} else {
+		go checkSchema(b)
+	}
+
+	var notifier *notify.Notifier
+	if *g.notifyConfig != "" {
+		if *g.snapshotInterval <= 0 {
+			return usageError(fmt.Errorf("--notify-config needs scoreboard snapshots, but --snapshot-interval is 0"))
+		}
+		if notifier, err = loadNotifier(*g.notifyConfig); err != nil {
+			return usageError(err)
+		}
+	}
+
+	if *g.snapshotInterval > *g.tickLength {
+		logging.Warn(context.Background(), "the snapshot interval is longer than a tick, so the dashboard, API and events will miss ticks", "snapshot_interval", *g.snapshotInterval, "tick_duration", *g.tickLength)
+	}
+
+	if *g.snapshotInterval > 0 {
+		snapshots := store.New(*g.historyTicks)
+		go store.Poll(context.Background(), b, snapshots, *g.snapshotInterval)
+
+		recent := events.NewRecent(recentEvents)
+		engine := events.NewEngine(recent)
+		if notifier != nil {
+			engine.AddSink(notifier)
+			go notifier.Run(context.Background())
+		}
+		go events.Run(context.Background(), snapshots, engine)
+
+		http.Handle("/", dashboard.Handler(snapshots))
+		http.Handle(api.Prefix, api.Handler(snapshots, recent))
+	}
+
+	return listen(g)
+}
+
+func loadNotifier(path string) (*notify.Notifier, error) {
+	cfg, err := notify.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return notify.New(cfg)
+}
+
+// notifyTest sends a test message to every sink of --notify-config.
+func notifyTest(g *globalFlags) int {
+	if *g.notifyConfig == "" {
+		return usageError(fmt.Errorf("notify-test needs --notify-config"))
+	}
+	notifier, err := loadNotifier(*g.notifyConfig)
+	if err != nil {
+		return usageError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	if err := notifier.Test(ctx); err != nil {
+		return runtimeError(err)
+	}
+	return exitOK
+}
+
+func serveFakeGameserver(g *globalFlags, args []string) int {
+	cleanup, err := metrics.Setup()
+	if err != nil {
+		return runtimeError(err)
+	}
+	defer cleanup()
+
+	gameserver := fakegameserver.New()
+	if err := gameserver.Init(args); err != nil {
+		return usageError(err)
+	}
+
+	return listen(g)
+}
+
+func listen(g *globalFlags) int {
+	readyWithin := time.Duration(*g.readyTicks) * *g.tickLength
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", status.Healthz)
+	http.Handle("/readyz", status.ReadyHandler(readyWithin))
+	http.Handle("/status", status.PageHandler(readyWithin))
+
+	logging.Info(context.Background(), "listening", "url", "http://"+*g.listenAddr+"/metrics")
+	if err := http.ListenAndServe(*g.listenAddr, nil); err != nil {
+		return runtimeError(fmt.Errorf("while listening: %w", err))
+	}
+	return exitOK
+}
+
+// fetch loads a backend's scoreboard once and prints it.
+func fetch(g *globalFlags, args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	format := fs.String("format", "table", "output format: table, json, csv or prom (Prometheus exposition text, as served on /metrics)")
+	if err := envflag.Parse(fs, args); err != nil {
+		return usageError(err)
+	}
+	if fs.NArg() == 0 {
+		return usageError(fmt.Errorf("fetch needs a backend: faustv1, faustv2, generic or ctfd"))
+	}
+
+	var write func(io.Writer, *scoreboard.Scoreboard) error
+	switch *format {
+	case "table":
+		write = scoreboard.WriteTable
+	case "json":
+		write = scoreboard.WriteJSON
+	case "csv":
+		write = scoreboard.WriteCSV
+	case "prom":
+	default:
+		return usageError(fmt.Errorf("unknown format %q, use table, json, csv or prom", *format))
+	}
+
+	b, err := newBackend(g, fs.Arg(0))
+	if err != nil {
+		return usageError(err)
+	}
+
+	stopRecording, err := startRecording(g)
+	if err != nil {
+		return usageError(err)
+	}
+	defer stopRecording()
+
+	if *format == "prom" {
+		return fetchMetrics(b, fs.Args()[1:])
+	}
+
+	if err := b.Configure(fs.Args()[1:]); err != nil {
+		return usageError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	sb, err := b.Scoreboard(ctx)
+	if err != nil {
+		return runtimeError(err)
+	}
+
+	if err := write(stdout, sb); err != nil {
+		return runtimeError(err)
+	}
+	return exitOK
+}
+
+// fetchMetrics prints the metrics of one scrape.
+func fetchMetrics(b backend, args []string) int {
+	cleanup, err := metrics.Setup()
+	if err != nil {
+		return runtimeError(err)
+	}
+	defer cleanup()
+
+	if err := b.Init(args); err != nil {
+		return usageError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	if err := b.Refresh(ctx); err != nil {
+		return runtimeError(err)
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return runtimeError(fmt.Errorf("while collecting metrics: %w", err))
+	}
+	enc := expfmt.NewEncoder(stdout, expfmt.FmtText)
+	for _, family := range families {
+		if err := enc.Encode(family); err != nil {
+			return runtimeError(err)
+		}
+	}
+	return exitOK
+}
+
+// watch shows a backend's scoreboard in the terminal until interrupted.
+func watchScoreboard(g *globalFlags, args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	team := fs.String("team", "", "name of the team to highlight")
+	interval := fs.Duration("interval", 5*time.Second, "how often to fetch the scoreboard")
+	if err := envflag.Parse(fs, args); err != nil {
+		return usageError(err)
+	}
+	if fs.NArg() == 0 {
+		return usageError(fmt.Errorf("watch needs a backend: faustv1, faustv2, generic or ctfd"))
+	}
+	if *interval <= 0 {
+		return usageError(fmt.Errorf("--interval must be positive"))
+	}
+
+	b, err := newBackend(g, fs.Arg(0))
+	if err != nil {
+		return usageError(err)
+	}
+	if err := b.Configure(fs.Args()[1:]); err != nil {
+		return usageError(err)
+	}
+
+	// fetch errors are shown on the screen instead
+	logging.SetOutput(io.Discard)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+	opts := watch.Options{
+		Team:     *team,
+		Interval: *interval,
+		Color:    !noColor,
+	}
+	if err := watch.Run(ctx, stdout, b, opts); err != nil {
+		return runtimeError(err)
+	}
+	return exitOK
+}
+
+// validate checks a backend's flags and config files, and the schema of the
+// scoreboard if it can be reached.
+func validate(name string, b backend, args []string) int {
+	if err := b.Configure(args); err != nil {
+		return usageError(err)
+	}
+	if err := checkSchema(b); err != nil {
+		return usageError(err)
+	}
+	fmt.Printf("%s configuration is valid\n", name)
+	return exitOK
+}
+
+// checkSchema fetches every document of the backend once, so that schema
+// drift is logged at startup. Drift is only an error with --strict, and then
+// counts as invalid configuration: the backend does not fit the gameserver. A
+// scoreboard that cannot be fetched is not, as the gameserver may not be up
+// yet.
+func checkSchema(b backend) error {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	err := b.CheckSchema(ctx)
+	if errors.Is(err, httpclient.ErrSchemaDrift) {
+		return err
+	}
+	if err != nil {
+		logging.Warn(ctx, "cannot check the scoreboard schema", "error", err)
+	}
+	return nil
+}
@@ -1,51 +1,281 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
 
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/envflag"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/ctfdexporter"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/exporterbase"
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/faustv1exporter"
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/faustv2exporter"
-	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/genericexporter"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/recorder"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
 )
 
-var (
-	listenAddr = flag.String("listenAddr", ":5001", "address to listen on (e.g. localhost:5001)")
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// Exit codes
+const (
+	exitOK = 0
+	// fetching or serving failed
+	exitError = 1
+	// the command line or the configuration is invalid
+	exitUsage = 2
 )
 
+// backend is a scoreboard exporter for one kind of API.
+type backend interface {
+	// SetTickDuration derives the cache TTL from --tick-duration. It is
+	// called before Configure.
+	SetTickDuration(tick time.Duration)
+	// Configure parses the backend's flags without registering metrics.
+	Configure(args []string) error
+	// Init configures the backend and registers its metrics.
+	Init(args []string) error
+	// Refresh loads the scoreboard, through the caches.
+	Refresh(ctx context.Context) error
+	// CheckSchema loads every document the backend decodes once.
+	CheckSchema(ctx context.Context) error
+	status.Source
+	scoreboard.Source
+}
+
+func newBackend(g *globalFlags, name string) (backend, error) {
+	var b backend
+	switch name {
+	case "faustv1":
+		exporter := faustv1exporter.New()
+		b = &exporter
+	case "faustv2":
+		exporter := faustv2exporter.New()
+		b = &exporter
+	case "generic":
+		exporter := genericexporter.New()
+		b = &exporter
+	case "ctfd":
+		exporter := ctfdexporter.New()
+		b = &exporter
+	default:
+		return nil, fmt.Errorf("unknown backend %q, use one of faustv1, faustv2, generic and ctfd", name)
+	}
+	b.SetTickDuration(*g.tickLength)
+	return b, nil
+}
+
+// globalFlags go before the command.
+type globalFlags struct {
+	fs         *flag.FlagSet
+	listenAddr *string
+	recordDir  *string
+	strict     *bool
+	readyTicks *int
+	tickLength *time.Duration
+	logLevel   *string
+	logFormat  *string
+	// how often serve adds the scoreboard to the snapshot store
+	snapshotInterval *time.Duration
+	historyTicks     *int
+	notifyConfig     *string
+}
+
+func newGlobalFlags() *globalFlags {
+	g := &globalFlags{
+		fs: flag.NewFlagSet("scoreboard_exporter", flag.ContinueOnError),
+	}
+
+	g.listenAddr = g.fs.String("listenAddr", ":5001", "address to listen on (e.g. localhost:5001)")
+	g.recordDir = g.fs.String("record-dir", "", "archive every fetched scoreboard document into this directory, gzipped, with an index.jsonl")
+	g.strict = g.fs.Bool("strict", false, "refuse scoreboard documents with unknown or missing fields instead of only reporting them")
+	g.readyTicks = g.fs.Int("ready-ticks", 3, "/readyz fails once the newest scoreboard is older than this many ticks")
+	g.tickLength = g.fs.Duration("tick-duration", 3*time.Minute, "length of a tick in the game, for --ready-ticks and the cache TTL, which is a quarter tick between 500ms and 10s")
+	g.logLevel = g.fs.String("log-level", "info", "least severe log lines to write: debug, info, warn or error")
+	g.logFormat = g.fs.String("log-format", "text", "log line format: text (logfmt) or json")
+	g.snapshotInterval = g.fs.Duration("snapshot-interval", 10*time.Second, "how often to keep a snapshot of the scoreboard for the dashboard at / and the API at /api/v1/, 0 turns both off; unless set, the cache TTL")
+	g.historyTicks = g.fs.Int("history-ticks", 1000, "how many ticks of scoreboard snapshots to keep in memory")
+	g.notifyConfig = g.fs.String("notify-config", "", "send chat notifications about our team's scoreboard events as configured in this YAML file, see examples/notify.yml")
+	g.fs.Usage = func() { usage(g.fs.Output(), g.fs) }
+
+	return g
+}
+
+// check validates the tick flags and derives the snapshot interval. /readyz
+// would flap if the scoreboard could be cached for longer than the readiness
+// window.
+func (g *globalFlags) check() error {
+	if *g.tickLength <= 0 {
+		return fmt.Errorf("--tick-duration must be positive")
+	}
+	if *g.readyTicks < 1 {
+		return fmt.Errorf("--ready-ticks must be at least 1")
+	}
+	ttl := exporterbase.CacheTTL(*g.tickLength)
+	if readyWithin := time.Duration(*g.readyTicks) * *g.tickLength; readyWithin < ttl {
+		return fmt.Errorf("--ready-ticks %d of --tick-duration %v is %v, shorter than the cache TTL of %v", *g.readyTicks, *g.tickLength, readyWithin, ttl)
+	}
+
+	intervalSet := false
+	g.fs.Visit(func(f *flag.Flag) {
+		if f.Name == "snapshot-interval" {
+			intervalSet = true
+		}
+	})
+	if !intervalSet {
+		*g.snapshotInterval = ttl
+	}
+	return nil
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprint(w, `Usage: scoreboard_exporter [global flags] <command> [flags]
+
+Commands:
+  serve <backend> [flags]     export a scoreboard as Prometheus metrics
+  <backend> [flags]           same as serve <backend>
+  fetch [--format f] <backend> [flags]
+                              fetch the scoreboard once and print it as a
+                              table, json, csv or prom (exposition text)
+  watch [--team t] <backend> [flags]
+                              show a live scoreboard in the terminal
+  validate <backend> [flags]  check the flags, the config files and the
+                              scoreboard schema
+  notify-test                 send a test message to every sink of --notify-config
+  fake-gameserver [flags]     serve a fake ctf-gameserver scoreboard for testing
+  version                     print the version
+  help                        print this help
+
+Backends: faustv1, faustv2, generic, ctfd
+Run "scoreboard_exporter validate <backend> --help" for the flags of a backend.
+
+Every flag can also be set through the environment variable shown in
+brackets. Flags on the command line take precedence.
+
+Exit codes: 0 success, 1 fetching or serving failed, 2 invalid usage or
+configuration.
+
+Global flags:
+`)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
 func main() {
-	flag.Parse()
-	rest := flag.Args()
+	os.Exit(run(os.Args[1:]))
+}
 
-	if cleanup, err := metrics.Setup(); err != nil {
-		log.Fatalf("error setting up metrics: %v", err)
-	} else {
-		defer cleanup()
+func run(args []string) int {
+	g := newGlobalFlags()
+	if err := envflag.Parse(g.fs, args); err != nil {
+		return usageError(err)
 	}
 
-	subcmd := rest[0]
-	args := rest[1:]
+	if err := logging.Setup(*g.logLevel, *g.logFormat); err != nil {
+		return usageError(err)
+	}
+	log.SetFlags(0)
+	log.SetOutput(logging.StdWriter())
 
-	if subcmd == "faustv1" {
-		exporter := faustv1exporter.New()
-		if err := exporter.Init(args); err != nil {
-			log.Fatalf("error: %v", err)
+	if err := g.check(); err != nil {
+		return usageError(err)
+	}
+
+	httpclient.SetStrictSchema(*g.strict)
+
+	rest := g.fs.Args()
+	if len(rest) == 0 {
+		usage(os.Stderr, g.fs)
+		return exitUsage
+	}
+	command, args := rest[0], rest[1:]
+
+	switch command {
+	case "help", "-h", "--help":
+		usage(os.Stdout, g.fs)
+		return exitOK
+	case "version":
+		printVersion()
+		return exitOK
+	case "fake-gameserver":
+		return serveFakeGameserver(g, args)
+	case "fetch":
+		return fetch(g, args)
+	case "watch":
+		return watchScoreboard(g, args)
+	case "notify-test":
+		return notifyTest(g)
+	case "serve", "validate":
+		if len(args) == 0 {
+			return usageError(fmt.Errorf("%s needs a backend: faustv1, faustv2, generic or ctfd", command))
 		}
-	} else if subcmd == "faustv2" {
-		exporter := faustv2exporter.New()
-		if err := exporter.Init(args); err != nil {
-			log.Fatalf("error: %v", err)
+		b, err := newBackend(g, args[0])
+		if err != nil {
+			return usageError(err)
 		}
-	} else {
-		log.Fatalf("subcmd is not accepted! only faustv1 and faustv2 are accepted")
+		if command == "serve" {
+			return serve(g, b, args[1:])
+		}
+		return validate(args[0], b, args[1:])
 	}
 
-	http.Handle("/metrics", promhttp.Handler())
+	// scoreboard_exporter <backend> is short for serve <backend>
+	b, err := newBackend(g, command)
+	if err != nil {
+		return usageError(fmt.Errorf("unknown command %q, run with --help for usage", command))
+	}
+	return serve(g, b, args)
+}
 
-	log.Printf("Listening on http://%s/metrics", *listenAddr)
-	if err := http.ListenAndServe(*listenAddr, nil); err != nil {
-		log.Fatalf("error while listening: %v", err)
+// usageError reports an invalid command line or configuration. --help is
+// not an error.
+func usageError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "scoreboard_exporter: %v\n", err)
+	return exitUsage
+}
+
+// runtimeError reports a failure while fetching or serving.
+func runtimeError(err error) int {
+	fmt.Fprintf(os.Stderr, "scoreboard_exporter: %v\n", err)
+	return exitError
+}
+
+// startRecording sets up --record-dir. The returned function closes the
+// archive.
+func startRecording(g *globalFlags) (func(), error) {
+	if *g.recordDir == "" {
+		return func() {}, nil
+	}
+	rec, err := recorder.New(*g.recordDir)
+	if err != nil {
+		return nil, fmt.Errorf("while setting up recording: %w", err)
+	}
+	httpclient.Use(rec.Wrap)
+	return func() { rec.Close() }, nil
+}
+
+func printVersion() {
+	revision := "unknown revision"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
 	}
+	fmt.Printf("scoreboard_exporter %s (%s, %s)\n", version, revision, runtime.Version())
 }
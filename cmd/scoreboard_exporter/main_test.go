@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+)
+
+func TestValidateSchemaDrift(t *testing.T) {
+	sampleData, err := filepath.Abs("../../sample-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileURLs := []string{
+		"--current-url", "file://" + sampleData + "/example-scoreboard-current.json",
+		"--round-url", "file://" + sampleData + "/scoreboard_round_%d.json",
+		"--teams-url", "file://" + sampleData + "/scoreboard_teams.json",
+	}
+
+	tests := []struct {
+		name   string
+		strict bool
+		args   []string
+		want   int
+	}{
+		// scoreboard_teams.json has an unknown country and no vulnbox
+		{name: "replay", args: []string{"--replay-dir", sampleData}, want: exitOK},
+		{name: "replay strict", strict: true, args: []string{"--replay-dir", sampleData}, want: exitUsage},
+		{name: "files strict", strict: true, args: fileURLs, want: exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { httpclient.SetStrictSchema(false) })
+
+			args := []string{"--log-level", "error"}
+			if tt.strict {
+				args = append(args, "--strict")
+			}
+			args = append(args, "validate", "faustv2")
+			if got := run(append(args, tt.args...)); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// The Prometheus text of fetch --format prom, for the families that come from
+// the scoreboard and one team. The other families hold timings and ages.
+func TestFetchProm(t *testing.T) {
+	var buf bytes.Buffer
+	stdout = &buf
+	t.Cleanup(func() { stdout = os.Stdout })
+
+	args := []string{"--log-level", "error", "fetch", "--format", "prom", "faustv2", "--replay-dir", "../../sample-data"}
+	if got := run(args); got != exitOK {
+		t.Fatalf("exit code = %d, want %d", got, exitOK)
+	}
+
+	families := []string{
+		"scoreboard_tick", "scoreboard_round_lag_ticks", "scoreboard_offense",
+		"scoreboard_defense", "scoreboard_sla", "scoreboard_captures", "scoreboard_stolen",
+	}
+	var got bytes.Buffer
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name := strings.TrimPrefix(strings.TrimPrefix(line, "# HELP "), "# TYPE ")
+		if i := strings.IndexAny(name, " {"); i >= 0 {
+			name = name[:i]
+		}
+		if !contains(families, name) {
+			continue
+		}
+		if strings.Contains(line, "team=") && !strings.Contains(line, `team="ENOFLAG"`) {
+			continue
+		}
+		got.WriteString(line + "\n")
+	}
+
+	path := filepath.Join("testdata", "fetch_faustv2.prom.golden")
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, got.Bytes(), want)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
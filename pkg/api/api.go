@@ -0,0 +1,196 @@
+// Package api serves the scoreboard snapshots as backend-neutral JSON under
+// /api/v1/, so that other tools can share the exporter's view of the
+// scoreboard instead of parsing the gameserver's documents themselves.
+//
+// Every response carries an ETag; requests with a matching If-None-Match get
+// an empty 304 Not Modified.
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+// Prefix is where the API is served.
+const Prefix = "/api/v1/"
+
+// TeamsResponse is served on /api/v1/teams.
+type TeamsResponse struct {
+	Source string `json:"source"`
+	Tick   int64  `json:"tick"`
+	// teams in rank order, without their services
+	Teams []scoreboard.Team `json:"teams"`
+}
+
+// ServicesResponse is served on /api/v1/services.
+type ServicesResponse struct {
+	Source   string               `json:"source"`
+	Tick     int64                `json:"tick"`
+	Services []scoreboard.Service `json:"services"`
+}
+
+// RoundsResponse is served on /api/v1/rounds.
+type RoundsResponse struct {
+	// ticks that /api/v1/rounds/{tick} can serve, oldest first
+	Ticks []int64 `json:"ticks"`
+}
+
+// EventsResponse is served on /api/v1/events.
+type EventsResponse struct {
+	// events of the ticks after ?since, oldest first
+	Events []events.Event `json:"events"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the API:
+//
+//	/api/v1/scoreboard       the newest scoreboard
+//	/api/v1/teams            its teams
+//	/api/v1/services         its services
+//	/api/v1/rounds           the ticks kept in memory
+//	/api/v1/rounds/{tick}    the scoreboard of a tick
+//	/api/v1/stream           server-sent events on every new tick
+//	/api/v1/events           events of the recent ticks
+func Handler(s *store.Store, recent *events.Recent) http.Handler {
+	stream := StreamHandler(s)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, req, http.StatusMethodNotAllowed, "only GET is supported")
+			return
+		}
+
+		path := strings.TrimPrefix(req.URL.Path, Prefix)
+		if path == "stream" {
+			stream.ServeHTTP(w, req)
+			return
+		}
+		if path == "rounds" {
+			ticks := []int64{}
+			for _, sb := range s.History(0) {
+				ticks = append(ticks, sb.Tick)
+			}
+			writeJSON(w, req, RoundsResponse{Ticks: ticks})
+			return
+		}
+
+		if path == "events" {
+			since := int64(-1)
+			if value := req.URL.Query().Get("since"); value != "" {
+				n, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					writeError(w, req, http.StatusBadRequest, "since must be a tick number")
+					return
+				}
+				since = n
+			}
+			writeJSON(w, req, EventsResponse{Events: recent.Since(since)})
+			return
+		}
+
+		if strings.HasPrefix(path, "rounds/") {
+			tick := strings.TrimPrefix(path, "rounds/")
+			n, err := strconv.ParseInt(tick, 10, 64)
+			if err != nil {
+				writeError(w, req, http.StatusBadRequest, "the tick must be a number")
+				return
+			}
+			sb, ok := s.Tick(n)
+			if !ok {
+				writeError(w, req, http.StatusNotFound, "tick "+tick+" is not kept in memory, see /api/v1/rounds")
+				return
+			}
+			writeJSON(w, req, sb)
+			return
+		}
+
+		sb := s.Latest()
+		switch path {
+		case "scoreboard", "teams", "services":
+			if sb == nil {
+				writeError(w, req, http.StatusServiceUnavailable, "no scoreboard has been fetched yet")
+				return
+			}
+		default:
+			writeError(w, req, http.StatusNotFound, "unknown endpoint, see the README for the API")
+			return
+		}
+
+		switch path {
+		case "scoreboard":
+			writeJSON(w, req, sb)
+		case "teams":
+			resp := TeamsResponse{Source: sb.Source, Tick: sb.Tick, Teams: make([]scoreboard.Team, len(sb.Teams))}
+			for idx, team := range sb.Teams {
+				team.Services = nil
+				resp.Teams[idx] = team
+			}
+			writeJSON(w, req, resp)
+		case "services":
+			writeJSON(w, req, ServicesResponse{Source: sb.Source, Tick: sb.Tick, Services: sb.Services})
+		}
+	})
+}
+
+// writeJSON sends v with an ETag derived from its encoding, or 304 Not
+// Modified if the client already has it.
+func writeJSON(w http.ResponseWriter, req *http.Request, v interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		logging.Error(req.Context(), "api: while encoding response", "path", req.URL.Path, "error", err)
+		writeError(w, req, http.StatusInternalServerError, "cannot encode the response")
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if matchesETag(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if req.Method == http.MethodHead {
+		return
+	}
+	w.Write(body.Bytes())
+}
+
+// matchesETag checks an If-None-Match header, which may list several tags or
+// be *.
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, req *http.Request, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if req.Method == http.MethodHead {
+		return
+	}
+	json.NewEncoder(w).Encode(errorResponse{Error: msg})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// board returns a scoreboard of a tick with two teams.
+func board(tick int64, points float64) *scoreboard.Scoreboard {
+	return &scoreboard.Scoreboard{
+		Source:    "faustv2",
+		Tick:      tick,
+		FetchedAt: time.Date(2023, 10, 9, 12, 0, int(tick), 0, time.UTC),
+		Services:  []scoreboard.Service{{Name: "web"}},
+		Teams: []scoreboard.Team{
+			{Rank: 1, ID: "1", Name: "alpha", Points: points, Services: []scoreboard.TeamService{{Service: "web", Status: "up"}}},
+			{Rank: 2, ID: "2", Name: "beta", Points: 10, Services: []scoreboard.TeamService{{Service: "web", Status: "down"}}},
+		},
+	}
+}
+
+func serveAPI(t *testing.T, s *store.Store) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(Prefix, Handler(s, events.NewRecent(10)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL + Prefix
+}
+
+// get requests an endpoint, with If-None-Match if etag is set.
+func get(t *testing.T, method string, url string, etag string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestETag(t *testing.T) {
+	s := store.New(10)
+	base := serveAPI(t, s)
+
+	if resp, _ := get(t, http.MethodGet, base+"scoreboard", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before the first scoreboard: %d, want 503", resp.StatusCode)
+	}
+
+	s.Add(board(1, 100))
+	for _, endpoint := range []string{"scoreboard", "teams", "services", "rounds", "rounds/1", "events"} {
+		t.Run(endpoint, func(t *testing.T) {
+			resp, body := get(t, http.MethodGet, base+endpoint, "")
+			etag := resp.Header.Get("ETag")
+			if resp.StatusCode != http.StatusOK || etag == "" || body == "" {
+				t.Fatalf("GET = %d with ETag %q and %d bytes, want 200 with an ETag and a body", resp.StatusCode, etag, len(body))
+			}
+
+			tests := []struct {
+				name        string
+				method      string
+				ifNoneMatch string
+				want        int
+				wantBody    bool
+			}{
+				{"match", http.MethodGet, etag, http.StatusNotModified, false},
+				{"weak match in a list", http.MethodGet, `"other", W/` + etag, http.StatusNotModified, false},
+				{"any", http.MethodGet, "*", http.StatusNotModified, false},
+				{"no match", http.MethodGet, `"other"`, http.StatusOK, true},
+				{"head", http.MethodHead, "", http.StatusOK, false},
+			}
+			for _, tt := range tests {
+				resp, body := get(t, tt.method, base+endpoint, tt.ifNoneMatch)
+				if resp.StatusCode != tt.want {
+					t.Errorf("%s: %d, want %d", tt.name, resp.StatusCode, tt.want)
+				}
+				if got := resp.Header.Get("ETag"); got != etag {
+					t.Errorf("%s: ETag %q, want %q", tt.name, got, etag)
+				}
+				if (body != "") != tt.wantBody {
+					t.Errorf("%s: body %q", tt.name, body)
+				}
+			}
+		})
+	}
+
+	resp, _ := get(t, http.MethodGet, base+"scoreboard", "")
+	first := resp.Header.Get("ETag")
+
+	// refetching the same tick with the same content keeps the ETag
+	refetched := board(1, 100)
+	refetched.FetchedAt = refetched.FetchedAt.Add(time.Minute)
+	s.Add(refetched)
+	if resp, _ := get(t, http.MethodGet, base+"scoreboard", first); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("after a refetch: %d, want 304", resp.StatusCode)
+	}
+
+	s.Add(board(2, 150))
+	resp, body := get(t, http.MethodGet, base+"scoreboard", first)
+	if resp.StatusCode != http.StatusOK || body == "" {
+		t.Errorf("after a new tick: %d, want 200 with the new scoreboard", resp.StatusCode)
+	}
+	second := resp.Header.Get("ETag")
+	if second == "" || second == first {
+		t.Errorf("ETag after a new tick = %q, want a new one", second)
+	}
+	if resp, _ := get(t, http.MethodGet, base+"scoreboard", second); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("with the new ETag: %d, want 304", resp.StatusCode)
+	}
+
+	// the old tick keeps its ETag under /rounds
+	if resp, _ := get(t, http.MethodGet, base+"rounds/1", first); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("rounds/1 with the first ETag: %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := store.New(10)
+	s.Add(board(1, 100))
+	base := serveAPI(t, s)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "scoreboard", http.StatusMethodNotAllowed},
+		{http.MethodGet, "unknown", http.StatusNotFound},
+		{http.MethodGet, "rounds/7", http.StatusNotFound},
+		{http.MethodGet, "rounds/latest", http.StatusBadRequest},
+		{http.MethodGet, "events?since=yesterday", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, tt.method, base+tt.path, "")
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+		if resp.Header.Get("ETag") != "" || body == "" {
+			t.Errorf("%s %s: ETag %q and body %q, want an error without an ETag", tt.method, tt.path, resp.Header.Get("ETag"), body)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+// how often an idle stream sends a comment, so that proxies keep it open
+const keepAliveInterval = 30 * time.Second
+
+// Diff is the change of the scoreboard from one tick to the next, sent on
+// /api/v1/stream?diff=1.
+type Diff struct {
+	Source       string     `json:"source"`
+	Tick         int64      `json:"tick"`
+	PreviousTick int64      `json:"previous_tick"`
+	TickUntil    *time.Time `json:"tick_until,omitempty"`
+	FetchedAt    time.Time  `json:"fetched_at"`
+	// services whose attackers, victims or first blood changed
+	Services []scoreboard.Service `json:"services,omitempty"`
+	// teams whose rank, points or services changed, in full
+	Teams []scoreboard.Team `json:"teams,omitempty"`
+	// names of teams that are no longer on the scoreboard
+	RemovedTeams []string `json:"removed_teams,omitempty"`
+}
+
+// NewDiff compares two scoreboards.
+func NewDiff(previous, next *scoreboard.Scoreboard) *Diff {
+	d := &Diff{
+		Source:       next.Source,
+		Tick:         next.Tick,
+		PreviousTick: previous.Tick,
+		TickUntil:    next.TickUntil,
+		FetchedAt:    next.FetchedAt,
+	}
+
+	services := make(map[string]scoreboard.Service, len(previous.Services))
+	for _, service := range previous.Services {
+		services[service.Name] = service
+	}
+	for _, service := range next.Services {
+		if before, ok := services[service.Name]; !ok || !reflect.DeepEqual(before, service) {
+			d.Services = append(d.Services, service)
+		}
+	}
+
+	teams := make(map[string]*scoreboard.Team, len(previous.Teams))
+	for idx := range previous.Teams {
+		teams[previous.Teams[idx].Name] = &previous.Teams[idx]
+	}
+	for _, team := range next.Teams {
+		before, ok := teams[team.Name]
+		if !ok || !reflect.DeepEqual(*before, team) {
+			d.Teams = append(d.Teams, team)
+		}
+		delete(teams, team.Name)
+	}
+	for _, team := range previous.Teams {
+		if _, ok := teams[team.Name]; ok {
+			d.RemovedTeams = append(d.RemovedTeams, team.Name)
+		}
+	}
+
+	return d
+}
+
+// StreamHandler pushes the scoreboard as server-sent events whenever a new
+// tick is seen. The first event is always the newest scoreboard in full, as
+// event "scoreboard". After that, every tick is sent in full too, or with
+// ?diff=1 as event "diff" holding only what changed. Ticks that are not newer
+// than the one already sent are left out.
+func StreamHandler(s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, req, http.StatusInternalServerError, "streaming is not supported")
+			return
+		}
+		diffs := req.URL.Query().Get("diff") == "1" || req.URL.Query().Get("diff") == "true"
+
+		ticks, unsubscribe := s.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// nginx buffers responses unless told otherwise
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "retry: 5000\n\n")
+		flusher.Flush()
+
+		sent := s.Latest()
+		if sent != nil {
+			if err := writeEvent(w, "scoreboard", sent.Tick, sent); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			var err error
+			select {
+			case <-req.Context().Done():
+				return
+			case <-keepAlive.C:
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			case sb := <-ticks:
+				// a tick added between subscribing and reading the newest
+				// scoreboard arrives once more
+				if sent != nil && !newer(sent, sb) {
+					continue
+				}
+				if diffs && sent != nil {
+					err = writeEvent(w, "diff", sb.Tick, NewDiff(sent, sb))
+				} else {
+					err = writeEvent(w, "scoreboard", sb.Tick, sb)
+				}
+				sent = sb
+			}
+			if err != nil {
+				logging.Debug(req.Context(), "api: stream closed", "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// newer reports whether sb comes after the scoreboard already sent. Without
+// ticks, every scoreboard the store adds is new.
+func newer(sent, sb *scoreboard.Scoreboard) bool {
+	if sent.Tick >= 0 || sb.Tick >= 0 {
+		return sb.Tick > sent.Tick
+	}
+	return sb != sent
+}
+
+func writeEvent(w http.ResponseWriter, event string, tick int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event, tick, data)
+	return err
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+func TestNewDiff(t *testing.T) {
+	previous := board(1, 100)
+	previous.Services[0].Attackers = 1
+
+	tests := []struct {
+		name        string
+		next        func(*scoreboard.Scoreboard)
+		wantTeams   []string
+		wantRemoved []string
+		wantService []string
+	}{
+		{
+			name: "unchanged",
+			next: func(sb *scoreboard.Scoreboard) {},
+		},
+		{
+			name: "points",
+			next: func(sb *scoreboard.Scoreboard) { sb.Teams[0].Points = 150 },
+			// only the team that changed
+			wantTeams: []string{"alpha"},
+		},
+		{
+			name:      "service status",
+			next:      func(sb *scoreboard.Scoreboard) { sb.Teams[1].Services[0].Status = "up" },
+			wantTeams: []string{"beta"},
+		},
+		{
+			name: "new and removed team",
+			next: func(sb *scoreboard.Scoreboard) {
+				sb.Teams[1] = scoreboard.Team{Rank: 2, ID: "3", Name: "gamma", Points: 5}
+			},
+			wantTeams:   []string{"gamma"},
+			wantRemoved: []string{"beta"},
+		},
+		{
+			name:        "attackers",
+			next:        func(sb *scoreboard.Scoreboard) { sb.Services[0].Attackers = 2 },
+			wantService: []string{"web"},
+		},
+		{
+			name: "new service",
+			next: func(sb *scoreboard.Scoreboard) {
+				sb.Services = append(sb.Services, scoreboard.Service{Name: "db"})
+			},
+			wantService: []string{"db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := board(2, 100)
+			next.Services[0].Attackers = 1
+			tt.next(next)
+
+			d := NewDiff(previous, next)
+			if d.Tick != 2 || d.PreviousTick != 1 || d.Source != "faustv2" || !d.FetchedAt.Equal(next.FetchedAt) {
+				t.Errorf("diff = %+v, want tick 2 after 1 of faustv2, fetched with the next scoreboard", d)
+			}
+
+			var teams []string
+			for _, team := range d.Teams {
+				teams = append(teams, team.Name)
+			}
+			if !reflect.DeepEqual(teams, tt.wantTeams) {
+				t.Errorf("teams = %q, want %q", teams, tt.wantTeams)
+			}
+			if !reflect.DeepEqual(d.RemovedTeams, tt.wantRemoved) {
+				t.Errorf("removed teams = %q, want %q", d.RemovedTeams, tt.wantRemoved)
+			}
+			var services []string
+			for _, service := range d.Services {
+				services = append(services, service.Name)
+			}
+			if !reflect.DeepEqual(services, tt.wantService) {
+				t.Errorf("services = %q, want %q", services, tt.wantService)
+			}
+		})
+	}
+}
+
+type event struct {
+	name string
+	id   int64
+	data string
+}
+
+// streamEvents reads the server-sent events of a stream until it ends. The
+// retry hint and keep-alive comments are left out.
+func streamEvents(t *testing.T, ctx context.Context, url string) <-chan event {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream = %d %s, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan event)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var e event
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "event":
+				e.name = value
+			case "id":
+				e.id, _ = strconv.ParseInt(value, 10, 64)
+			case "data":
+				e.data = value
+			case "":
+				if e.name != "" {
+					select {
+					case events <- e:
+					case <-ctx.Done():
+						return
+					}
+				}
+				e = event{}
+			}
+		}
+	}()
+	return events
+}
+
+func next(t *testing.T, events <-chan event) event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+	}
+	return event{}
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		// event names after the first scoreboard
+		want string
+	}{
+		{"full", "", "scoreboard"},
+		{"diff", "?diff=1", "diff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.New(10)
+			s.Add(board(1, 100))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := streamEvents(t, ctx, serveAPI(t, s)+"stream"+tt.query)
+
+			first := next(t, events)
+			var sb scoreboard.Scoreboard
+			if err := json.Unmarshal([]byte(first.data), &sb); err != nil {
+				t.Fatal(err)
+			}
+			if first.name != "scoreboard" || first.id != 1 || sb.Tick != 1 || len(sb.Teams) != 2 {
+				t.Fatalf("first event = %s %d with tick %d, want the scoreboard of tick 1 in full", first.name, first.id, sb.Tick)
+			}
+
+			// the stream has subscribed once the first event arrived; tick 0
+			// comes from a restarted gameserver and is older than what was sent
+			s.Add(board(2, 150))
+			s.Add(board(0, 0))
+			s.Add(board(3, 200))
+
+			for _, want := range []int64{2, 3} {
+				e := next(t, events)
+				if e.name != tt.want || e.id != want {
+					t.Fatalf("event = %s %d, want %s %d", e.name, e.id, tt.want, want)
+				}
+				if e.name != "diff" {
+					continue
+				}
+				// tick 3 is compared with tick 2, as tick 0 was not sent
+				var d Diff
+				if err := json.Unmarshal([]byte(e.data), &d); err != nil {
+					t.Fatal(err)
+				}
+				if d.PreviousTick != want-1 || len(d.Teams) != 1 || d.Teams[0].Name != "alpha" {
+					t.Errorf("diff = %+v, want alpha's points since tick %d", d, want-1)
+				}
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tickless := func() *scoreboard.Scoreboard { return board(-1, 100) }
+	same := tickless()
+
+	tests := []struct {
+		name       string
+		sent, next *scoreboard.Scoreboard
+		want       bool
+	}{
+		{"next tick", board(1, 0), board(2, 0), true},
+		{"same tick", board(2, 0), board(2, 0), false},
+		{"older tick", board(2, 0), board(1, 0), false},
+		{"without ticks", tickless(), tickless(), true},
+		{"the same scoreboard without ticks", same, same, false},
+	}
+	for _, tt := range tests {
+		if got := newer(tt.sent, tt.next); got != tt.want {
+			t.Errorf("%s: newer = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Value caches one fetched document. Fresh data is reused for the TTL. When a
+// refresh fails, the last good copy keeps being served until it is older than
+// the max staleness, so metrics go stale instead of disappearing.
+type Value[T any] struct {
+	name         string
+	ttl          time.Duration
+	maxStaleness time.Duration
+
+	mu        sync.Mutex
+	value     T
+	fetchedAt time.Time
+	valid     bool
+}
+
+// New creates a cache for the document called name.
+func New[T any](name string, ttl time.Duration, maxStaleness time.Duration) *Value[T] {
+	return &Value[T]{
+		name:         name,
+		ttl:          ttl,
+		maxStaleness: maxStaleness,
+	}
+}
+
+// Name is the document name the cache was created with.
+func (v *Value[T]) Name() string {
+	return v.name
+}
+
+// Get returns the cached value if it is fresh, and calls fetch otherwise.
+func (v *Value[T]) Get(ctx context.Context, fetch func(context.Context) (T, error)) (T, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if v.valid && v.fetchedAt.Add(v.ttl).After(now) {
+		metrics.ObserveCache(ctx, v.name, true)
+		return v.value, nil
+	}
+
+	metrics.ObserveCache(ctx, v.name, false)
+	data, err := fetch(ctx)
+	if err != nil {
+		if v.valid && v.fetchedAt.Add(v.maxStaleness).After(now) {
+			logging.Warn(ctx, "serving stale data", "document", v.name, "age", now.Sub(v.fetchedAt), "error", err)
+			return v.value, nil
+		}
+		var zero T
+		return zero, err
+	}
+
+	v.value = data
+	v.fetchedAt = time.Now()
+	v.valid = true
+	return data, nil
+}
+
+// Set stores a value that was fetched out of band, e.g. by a background
+// retry.
+func (v *Value[T]) Set(value T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.value = value
+	v.fetchedAt = time.Now()
+	v.valid = true
+}
+
+// Peek returns the last good value without fetching, if there is one.
+func (v *Value[T]) Peek() (T, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.value, v.valid
+}
+
+// Age returns how old the last good value is, or false if there is none.
+func (v *Value[T]) Age() (time.Duration, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.valid {
+		return 0, false
+	}
+	return time.Since(v.fetchedAt), true
+}
+
+// Aged is any cache, regardless of what it holds.
+type Aged interface {
+	Name() string
+	Age() (time.Duration, bool)
+}
+
+// ObserveAge reports the age of every cache that holds a value, labelled by
+// document name.
+func ObserveAge(observer metric.Float64Observer, caches ...Aged) {
+	for _, c := range caches {
+		age, ok := c.Age()
+		if !ok {
+			continue
+		}
+		observer.Observe(
+			age.Seconds(),
+			metric.WithAttributes(
+				attribute.String("document", c.Name()),
+			),
+		)
+	}
+}
@@ -0,0 +1,80 @@
+// Package dashboard serves a small web page with the current scoreboard, for
+// events where setting up Prometheus and Grafana is not worth it.
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+//go:embed index.html
+var page []byte
+
+// how many ticks of rank history the page shows by default
+const defaultHistoryTicks = 60
+
+// Handler serves the page at / and its data at /dashboard.json.
+func Handler(s *store.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("/dashboard.json", func(w http.ResponseWriter, req *http.Request) {
+		ticks := defaultHistoryTicks
+		if n, err := strconv.Atoi(req.URL.Query().Get("ticks")); err == nil && n > 0 {
+			ticks = n
+		}
+		writeData(w, req, s, ticks)
+	})
+	return mux
+}
+
+// data is what the page renders.
+type data struct {
+	// nil until the first scoreboard is fetched
+	Scoreboard *scoreboard.Scoreboard `json:"scoreboard"`
+	// rank and points of every team in the recent ticks, oldest first
+	History []tickRanks `json:"history"`
+}
+
+type tickRanks struct {
+	Tick  int64       `json:"tick"`
+	Teams []teamRanks `json:"teams"`
+}
+
+type teamRanks struct {
+	Name   string  `json:"name"`
+	Rank   int64   `json:"rank"`
+	Points float64 `json:"points"`
+}
+
+func writeData(w http.ResponseWriter, req *http.Request, s *store.Store, ticks int) {
+	d := data{
+		Scoreboard: s.Latest(),
+		History:    []tickRanks{},
+	}
+	for _, sb := range s.History(ticks) {
+		t := tickRanks{Tick: sb.Tick}
+		for _, team := range sb.Teams {
+			t.Teams = append(t.Teams, teamRanks{Name: team.Name, Rank: team.Rank, Points: team.Points})
+		}
+		d.History = append(d.History, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		logging.Debug(req.Context(), "dashboard: while writing response", "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Package envflag lets every command line flag also be set through an
+// environment variable: --base-url through SCOREBOARD_EXPORTER_BASE_URL,
+// --listenAddr through SCOREBOARD_EXPORTER_LISTEN_ADDR. Flags given on the
+// command line take precedence.
+package envflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const Prefix = "SCOREBOARD_EXPORTER_"
+
+// Name returns the environment variable for a flag.
+func Name(flagName string) string {
+	var b strings.Builder
+	b.WriteString(Prefix)
+	for i, r := range flagName {
+		switch {
+		case r == '-' || r == '.':
+			b.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteRune('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// Parse parses args, and then sets the flags that args did not set from the
+// environment. A flag given on the command line ignores its environment
+// variable entirely, so repeatable flags do not collect values from both. The
+// environment variable of each flag is added to its help text.
+func Parse(fs *flag.FlagSet, args []string) error {
+	fs.VisitAll(func(f *flag.Flag) {
+		env := Name(f.Name)
+		if !strings.HasSuffix(f.Usage, "[$"+env+"]") {
+			f.Usage += " [$" + env + "]"
+		}
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || err != nil {
+			return
+		}
+		env := Name(f.Name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, env, setErr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,115 @@
+package envflag
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"base-url":      "SCOREBOARD_EXPORTER_BASE_URL",
+		"listenAddr":    "SCOREBOARD_EXPORTER_LISTEN_ADDR",
+		"tls.ca":        "SCOREBOARD_EXPORTER_TLS_CA",
+		"tick-duration": "SCOREBOARD_EXPORTER_TICK_DURATION",
+	}
+	for flagName, want := range tests {
+		if got := Name(flagName); got != want {
+			t.Errorf("Name(%q) = %q, want %q", flagName, got, want)
+		}
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		wantURL     string
+		wantHeaders []string
+		wantArgs    []string
+	}{
+		{
+			name:        "defaults",
+			wantURL:     "default",
+			wantHeaders: nil,
+		},
+		{
+			name:        "environment only",
+			env:         map[string]string{"SCOREBOARD_EXPORTER_BASE_URL": "env", "SCOREBOARD_EXPORTER_HEADER": "X-Env: 1"},
+			wantURL:     "env",
+			wantHeaders: []string{"X-Env: 1"},
+		},
+		{
+			name:        "command line wins",
+			env:         map[string]string{"SCOREBOARD_EXPORTER_BASE_URL": "env"},
+			args:        []string{"--base-url", "cli"},
+			wantURL:     "cli",
+			wantHeaders: nil,
+		},
+		{
+			name:        "repeatable flag is not merged",
+			env:         map[string]string{"SCOREBOARD_EXPORTER_HEADER": "X-Env: 1"},
+			args:        []string{"--header", "X-Cli: 1", "--header", "X-Cli: 2", "rest"},
+			wantURL:     "default",
+			wantHeaders: []string{"X-Cli: 1", "X-Cli: 2"},
+			wantArgs:    []string{"rest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			baseURL := fs.String("base-url", "default", "")
+			var headers listFlag
+			fs.Var(&headers, "header", "")
+
+			if err := Parse(fs, tt.args); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if *baseURL != tt.wantURL {
+				t.Errorf("base-url = %q, want %q", *baseURL, tt.wantURL)
+			}
+			if !reflect.DeepEqual([]string(headers), tt.wantHeaders) {
+				t.Errorf("headers = %q, want %q", headers, tt.wantHeaders)
+			}
+			if strings.Join(fs.Args(), " ") != strings.Join(tt.wantArgs, " ") {
+				t.Errorf("args = %q, want %q", fs.Args(), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseInvalidEnvironment(t *testing.T) {
+	t.Setenv("SCOREBOARD_EXPORTER_READY_TICKS", "many")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("ready-ticks", 3, "")
+	err := Parse(fs, nil)
+	if err == nil || !strings.Contains(err.Error(), "SCOREBOARD_EXPORTER_READY_TICKS") {
+		t.Fatalf("Parse error = %v, want one naming the variable", err)
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("base-url", "", "URL of the gameserver")
+	for i := 0; i < 2; i++ {
+		if err := Parse(fs, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if usage := fs.Lookup("base-url").Usage; usage != "URL of the gameserver [$SCOREBOARD_EXPORTER_BASE_URL]" {
+		t.Errorf("usage = %q", usage)
+	}
+}
@@ -0,0 +1,316 @@
+// Package events compares consecutive scoreboards and reports what happened
+// in between, e.g. a service going down or a team losing flags, so that
+// consumers do not have to derive it from the raw numbers themselves.
+//
+// Events are counted in scoreboard_events_total, logged (one summary line per
+// tick, every event at debug level) and handed to every Sink.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/store"
+)
+
+type Kind string
+
+const (
+	// the scoreboard moved on to a new tick
+	TickAdvanced Kind = "tick_advanced"
+	// a team's service changed its status, e.g. from up to down
+	ServiceStatusChanged Kind = "service_status_changed"
+	// a team captured flags of a service after not doing so in the previous
+	// tick
+	StealingStarted Kind = "stealing_started"
+	// a team was the first to capture a flag of a service
+	FirstBlood Kind = "first_blood"
+	// a team moved past another team in the ranking
+	RankOvertake Kind = "rank_overtake"
+	// a team lost flags on a service
+	FlagsLost Kind = "flags_lost"
+	// more teams are capturing flags of a service than before
+	AttackersIncreased Kind = "attackers_increased"
+)
+
+// kinds in the order they are summarised in the logs
+var kinds = []Kind{TickAdvanced, ServiceStatusChanged, StealingStarted, FirstBlood, RankOvertake, FlagsLost, AttackersIncreased}
+
+// Valid reports whether k is one of the kinds above.
+func (k Kind) Valid() bool {
+	for _, kind := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Kind   Kind      `json:"kind"`
+	Source string    `json:"source"`
+	Tick   int64     `json:"tick"`
+	At     time.Time `json:"at"`
+	// the team the event is about, if any
+	Team string `json:"team,omitempty"`
+	// the service the event is about, if any
+	Service string `json:"service,omitempty"`
+	// the team that was overtaken, for rank_overtake
+	OtherTeam string `json:"other_team,omitempty"`
+	// the value before and after: the status for service_status_changed, the
+	// rank for rank_overtake, the number of attackers for
+	// attackers_increased and the tick for tick_advanced
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// number of flags, for flags_lost
+	Count int64 `json:"count,omitempty"`
+}
+
+// String describes the event in a short sentence.
+func (e Event) String() string {
+	switch e.Kind {
+	case TickAdvanced:
+		return fmt.Sprintf("tick %s started", e.New)
+	case ServiceStatusChanged:
+		return fmt.Sprintf("%s: %s is %s (was %s)", e.Team, e.Service, e.New, e.Old)
+	case StealingStarted:
+		return fmt.Sprintf("%s started capturing flags of %s", e.Team, e.Service)
+	case FirstBlood:
+		return fmt.Sprintf("%s got first blood on %s", e.Team, e.Service)
+	case RankOvertake:
+		return fmt.Sprintf("%s overtook %s and is now rank %s (was %s)", e.Team, e.OtherTeam, e.New, e.Old)
+	case FlagsLost:
+		return fmt.Sprintf("%s lost %d flags on %s", e.Team, e.Count, e.Service)
+	case AttackersIncreased:
+		return fmt.Sprintf("%s is exploited by %s teams (was %s)", e.Service, e.New, e.Old)
+	}
+	return string(e.Kind)
+}
+
+// Sink receives the events of every new tick. Handle is called from the
+// engine's goroutine, so it should not block for long.
+type Sink interface {
+	Handle(ctx context.Context, events []Event)
+}
+
+// Engine turns a sequence of scoreboards into events.
+type Engine struct {
+	mu       sync.Mutex
+	previous *scoreboard.Scoreboard
+	// whether a team captured flags of a service in the previous tick
+	stealing map[teamService]bool
+	sinks    []Sink
+}
+
+type teamService struct {
+	team    string
+	service string
+}
+
+func NewEngine(sinks ...Sink) *Engine {
+	return &Engine{
+		stealing: make(map[teamService]bool),
+		sinks:    sinks,
+	}
+}
+
+// AddSink adds a receiver for the events of later ticks.
+func (e *Engine) AddSink(sink Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks = append(e.sinks, sink)
+}
+
+// Observe compares a scoreboard with the previous one and reports the events
+// in between. The first scoreboard only sets the baseline. Scoreboards of the
+// same tick, or of an earlier one after a restart of the game, produce no
+// events.
+func (e *Engine) Observe(ctx context.Context, sb *scoreboard.Scoreboard) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous := e.previous
+	if previous != nil && !scoreboard.Advanced(previous, sb) {
+		return nil
+	}
+	e.previous = sb
+
+	if previous == nil || sb.Tick < previous.Tick {
+		e.stealing = make(map[teamService]bool)
+		for _, team := range sb.Teams {
+			for _, service := range team.Services {
+				e.stealing[teamService{team.Name, service.Service}] = service.CapturesDelta > 0
+			}
+		}
+		return nil
+	}
+
+	events := e.compare(previous, sb)
+
+	counts := make(map[Kind]int)
+	for _, event := range events {
+		counts[event.Kind]++
+		metrics.CountEvent(ctx, string(event.Kind), event.Team, event.Service)
+		logging.Debug(ctx, "scoreboard event", "kind", event.Kind, "tick", event.Tick, "event", event.String())
+	}
+	summary := []interface{}{"tick", sb.Tick, "previous_tick", previous.Tick}
+	for _, kind := range kinds {
+		if counts[kind] > 0 {
+			summary = append(summary, string(kind), counts[kind])
+		}
+	}
+	logging.Info(ctx, "scoreboard events", summary...)
+
+	for _, sink := range e.sinks {
+		sink.Handle(ctx, events)
+	}
+	return events
+}
+
+func (e *Engine) compare(previous, next *scoreboard.Scoreboard) []Event {
+	now := time.Now()
+	newEvent := func(kind Kind) Event {
+		return Event{Kind: kind, Source: next.Source, Tick: next.Tick, At: now}
+	}
+
+	var events []Event
+
+	if next.Tick >= 0 {
+		event := newEvent(TickAdvanced)
+		event.Old = strconv.FormatInt(previous.Tick, 10)
+		event.New = strconv.FormatInt(next.Tick, 10)
+		events = append(events, event)
+	}
+
+	services := make(map[string]scoreboard.Service, len(previous.Services))
+	for _, service := range previous.Services {
+		services[service.Name] = service
+	}
+	for _, service := range next.Services {
+		before, ok := services[service.Name]
+		if !ok {
+			continue
+		}
+
+		hadFirstBlood := make(map[string]bool)
+		for _, team := range before.FirstBlood {
+			hadFirstBlood[team] = true
+		}
+		for _, team := range service.FirstBlood {
+			if !hadFirstBlood[team] {
+				event := newEvent(FirstBlood)
+				event.Team = team
+				event.Service = service.Name
+				events = append(events, event)
+			}
+		}
+
+		if service.Attackers > before.Attackers {
+			event := newEvent(AttackersIncreased)
+			event.Service = service.Name
+			event.Old = strconv.FormatInt(before.Attackers, 10)
+			event.New = strconv.FormatInt(service.Attackers, 10)
+			events = append(events, event)
+		}
+	}
+
+	teams := make(map[string]*scoreboard.Team, len(previous.Teams))
+	for idx := range previous.Teams {
+		teams[previous.Teams[idx].Name] = &previous.Teams[idx]
+	}
+
+	for _, team := range next.Teams {
+		before := teams[team.Name]
+
+		beforeServices := make(map[string]scoreboard.TeamService)
+		if before != nil {
+			for _, service := range before.Services {
+				beforeServices[service.Service] = service
+			}
+		}
+
+		for _, service := range team.Services {
+			old, ok := beforeServices[service.Service]
+			key := teamService{team.Name, service.Service}
+
+			if ok && old.Status != "" && service.Status != "" && old.Status != service.Status {
+				event := newEvent(ServiceStatusChanged)
+				event.Team = team.Name
+				event.Service = service.Service
+				event.Old = old.Status
+				event.New = service.Status
+				events = append(events, event)
+			}
+
+			captured := service.CapturesDelta
+			lost := service.StolenDelta
+			if ok {
+				// the totals also cover ticks that were not seen
+				captured = service.Captures - old.Captures
+				lost = service.Stolen - old.Stolen
+			}
+
+			if captured > 0 && !e.stealing[key] {
+				event := newEvent(StealingStarted)
+				event.Team = team.Name
+				event.Service = service.Service
+				events = append(events, event)
+			}
+			e.stealing[key] = captured > 0
+
+			if lost > 0 {
+				event := newEvent(FlagsLost)
+				event.Team = team.Name
+				event.Service = service.Service
+				event.Count = lost
+				events = append(events, event)
+			}
+		}
+
+		if before == nil || team.Rank >= before.Rank {
+			continue
+		}
+		for _, other := range next.Teams {
+			was := teams[other.Name]
+			if was == nil || other.Name == team.Name {
+				continue
+			}
+			if was.Rank < before.Rank && other.Rank > team.Rank {
+				event := newEvent(RankOvertake)
+				event.Team = team.Name
+				event.OtherTeam = other.Name
+				event.Old = strconv.FormatInt(before.Rank, 10)
+				event.New = strconv.FormatInt(team.Rank, 10)
+				events = append(events, event)
+			}
+		}
+	}
+
+	return events
+}
+
+// Run feeds every new tick of the store to the engine, until ctx is
+// cancelled.
+func Run(ctx context.Context, s *store.Store, e *Engine) {
+	ticks, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	if latest := s.Latest(); latest != nil {
+		e.Observe(ctx, latest)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sb := <-ticks:
+			e.Observe(ctx, sb)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func board(tick int64, services []scoreboard.Service, teams ...scoreboard.Team) *scoreboard.Scoreboard {
+	return &scoreboard.Scoreboard{Source: "test", Tick: tick, Services: services, Teams: teams}
+}
+
+func team(name string, rank int64, services ...scoreboard.TeamService) scoreboard.Team {
+	return scoreboard.Team{Name: name, Rank: rank, Services: services}
+}
+
+func service(name string, status string, captures int64, stolen int64) scoreboard.TeamService {
+	return scoreboard.TeamService{Service: name, Status: status, Captures: captures, Stolen: stolen}
+}
+
+var web = []scoreboard.Service{{Name: "web"}}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *scoreboard.Scoreboard
+		next     *scoreboard.Scoreboard
+		want     []Event
+	}{
+		{
+			name:     "nothing but the tick",
+			previous: board(1, web, team("alpha", 1, service("web", "up", 0, 0))),
+			next:     board(2, web, team("alpha", 1, service("web", "up", 0, 0))),
+			want:     []Event{{Kind: TickAdvanced, Old: "1", New: "2"}},
+		},
+		{
+			name:     "skipped ticks",
+			previous: board(1, web, team("alpha", 1)),
+			next:     board(4, web, team("alpha", 1)),
+			want:     []Event{{Kind: TickAdvanced, Old: "1", New: "4"}},
+		},
+		{
+			name:     "service goes down",
+			previous: board(1, web, team("alpha", 1, service("web", "up", 0, 0))),
+			next:     board(2, web, team("alpha", 1, service("web", "down", 0, 0))),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: ServiceStatusChanged, Team: "alpha", Service: "web", Old: "up", New: "down"},
+			},
+		},
+		{
+			name:     "unknown status is not a change",
+			previous: board(1, web, team("alpha", 1, service("web", "", 0, 0))),
+			next:     board(2, web, team("alpha", 1, service("web", "down", 0, 0))),
+			want:     []Event{{Kind: TickAdvanced, Old: "1", New: "2"}},
+		},
+		{
+			name:     "stealing starts",
+			previous: board(1, web, team("alpha", 1, service("web", "up", 0, 0))),
+			next:     board(2, web, team("alpha", 1, service("web", "up", 3, 0))),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: StealingStarted, Team: "alpha", Service: "web"},
+			},
+		},
+		{
+			name:     "flags lost",
+			previous: board(1, web, team("alpha", 1, service("web", "up", 0, 1))),
+			next:     board(2, web, team("alpha", 1, service("web", "up", 0, 4))),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: FlagsLost, Team: "alpha", Service: "web", Count: 3},
+			},
+		},
+		{
+			name:     "first blood",
+			previous: board(1, []scoreboard.Service{{Name: "web"}}, team("alpha", 1)),
+			next:     board(2, []scoreboard.Service{{Name: "web", FirstBlood: []string{"alpha"}}}, team("alpha", 1)),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: FirstBlood, Team: "alpha", Service: "web"},
+			},
+		},
+		{
+			name:     "first blood is only reported once",
+			previous: board(1, []scoreboard.Service{{Name: "web", FirstBlood: []string{"alpha"}}}, team("alpha", 1)),
+			next:     board(2, []scoreboard.Service{{Name: "web", FirstBlood: []string{"alpha"}}}, team("alpha", 1)),
+			want:     []Event{{Kind: TickAdvanced, Old: "1", New: "2"}},
+		},
+		{
+			name:     "new service",
+			previous: board(1, nil, team("alpha", 1)),
+			next:     board(2, []scoreboard.Service{{Name: "web", Attackers: 2, FirstBlood: []string{"alpha"}}}, team("alpha", 1)),
+			want:     []Event{{Kind: TickAdvanced, Old: "1", New: "2"}},
+		},
+		{
+			name:     "attackers increase",
+			previous: board(1, []scoreboard.Service{{Name: "web", Attackers: 1}, {Name: "db", Attackers: 3}}, team("alpha", 1)),
+			next:     board(2, []scoreboard.Service{{Name: "web", Attackers: 3}, {Name: "db", Attackers: 2}}, team("alpha", 1)),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: AttackersIncreased, Service: "web", Old: "1", New: "3"},
+			},
+		},
+		{
+			name:     "rank overtake",
+			previous: board(1, nil, team("alpha", 3), team("beta", 2), team("gamma", 1)),
+			next:     board(2, nil, team("alpha", 1), team("beta", 3), team("gamma", 2)),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: RankOvertake, Team: "alpha", OtherTeam: "beta", Old: "3", New: "1"},
+				{Kind: RankOvertake, Team: "alpha", OtherTeam: "gamma", Old: "3", New: "1"},
+			},
+		},
+		{
+			name:     "new team uses the deltas",
+			previous: board(1, web, team("alpha", 1)),
+			next: board(2, web, team("alpha", 1), team("beta", 2, scoreboard.TeamService{
+				Service: "web", Captures: 9, CapturesDelta: 2, Stolen: 9, StolenDelta: 1,
+			})),
+			want: []Event{
+				{Kind: TickAdvanced, Old: "1", New: "2"},
+				{Kind: StealingStarted, Team: "beta", Service: "web"},
+				{Kind: FlagsLost, Team: "beta", Service: "web", Count: 1},
+			},
+		},
+		{
+			// without ticks, a change of points makes a new scoreboard
+			name:     "no ticks",
+			previous: board(-1, nil, scoreboard.Team{Name: "beta", Rank: 1, Points: 10}, scoreboard.Team{Name: "alpha", Rank: 2, Points: 5}),
+			next:     board(-1, nil, scoreboard.Team{Name: "alpha", Rank: 1, Points: 15}, scoreboard.Team{Name: "beta", Rank: 2, Points: 10}),
+			want: []Event{
+				{Kind: RankOvertake, Team: "alpha", OtherTeam: "beta", Old: "2", New: "1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine()
+			engine.Observe(context.Background(), tt.previous)
+			got := engine.Observe(context.Background(), tt.next)
+
+			for idx := range got {
+				if got[idx].Source != "test" || got[idx].Tick != tt.next.Tick || got[idx].At.IsZero() {
+					t.Errorf("event %d = %+v, want the source, tick and time of the next scoreboard", idx, got[idx])
+				}
+				got[idx].Source = ""
+				got[idx].Tick = 0
+				got[idx].At = time.Time{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	baseline := board(1, web, team("alpha", 1, service("web", "up", 0, 0)))
+	steps := []struct {
+		name string
+		sb   *scoreboard.Scoreboard
+		want []Kind
+	}{
+		{"baseline", baseline, nil},
+		{"same tick", board(1, web, team("alpha", 1, service("web", "down", 5, 5))), nil},
+		{"stealing starts", board(2, web, team("alpha", 1, service("web", "up", 2, 0))), []Kind{TickAdvanced, StealingStarted}},
+		{"still stealing", board(3, web, team("alpha", 1, service("web", "up", 4, 0))), []Kind{TickAdvanced}},
+		{"pause", board(4, web, team("alpha", 1, service("web", "up", 4, 0))), []Kind{TickAdvanced}},
+		{"stealing again", board(5, web, team("alpha", 1, service("web", "up", 5, 0))), []Kind{TickAdvanced, StealingStarted}},
+		{"game restarted", board(1, web, team("alpha", 1, service("web", "up", 0, 0))), nil},
+		{"after the restart", board(2, web, team("alpha", 1, service("web", "down", 0, 1))), []Kind{TickAdvanced, ServiceStatusChanged, FlagsLost}},
+	}
+
+	recent := NewRecent(100)
+	engine := NewEngine(recent)
+	total := 0
+	for _, step := range steps {
+		got := engine.Observe(context.Background(), step.sb)
+		var kinds []Kind
+		for _, event := range got {
+			kinds = append(kinds, event.Kind)
+		}
+		if !reflect.DeepEqual(kinds, step.want) {
+			t.Fatalf("%s: kinds = %v, want %v", step.name, kinds, step.want)
+		}
+		total += len(got)
+	}
+
+	if got := len(recent.Since(-1)); got != total {
+		t.Errorf("sink got %d events, want %d", got, total)
+	}
+}
+
+func TestObserveBaselineStealing(t *testing.T) {
+	engine := NewEngine()
+	engine.Observe(context.Background(), board(1, web, team("alpha", 1, scoreboard.TeamService{
+		Service: "web", Captures: 1, CapturesDelta: 1,
+	})))
+	got := engine.Observe(context.Background(), board(2, web, team("alpha", 1, service("web", "", 3, 0))))
+	if len(got) != 1 || got[0].Kind != TickAdvanced {
+		t.Errorf("events = %+v, want only %s, as alpha was already stealing", got, TickAdvanced)
+	}
+}
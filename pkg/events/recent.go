@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Recent is a Sink that keeps the newest events in memory.
+type Recent struct {
+	mu     sync.RWMutex
+	limit  int
+	events []Event
+}
+
+// NewRecent keeps up to limit events.
+func NewRecent(limit int) *Recent {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Recent{limit: limit}
+}
+
+func (r *Recent) Handle(ctx context.Context, events []Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, events...)
+	if len(r.events) > r.limit {
+		r.events = append([]Event(nil), r.events[len(r.events)-r.limit:]...)
+	}
+}
+
+// Since returns the kept events of ticks after tick, oldest first.
+func (r *Recent) Since(tick int64) []Event {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []Event{}
+	for _, event := range r.events {
+		if event.Tick > tick {
+			events = append(events, event)
+		}
+	}
+	return events
+}
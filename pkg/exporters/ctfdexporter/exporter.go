@@ -0,0 +1,384 @@
+package ctfdexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/exporterbase"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/ctfd"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const SCOPE_NAME string = "ctfd_exporter"
+
+// CTFdExporter exports a CTFd scoreboard, for jeopardy side events: points
+// and rank per team, and solves, value and first blood per challenge. There
+// are no ticks or services.
+type CTFdExporter struct {
+	*exporterbase.Base
+	baseURL       *string
+	scoreboardURL *string
+	challengesURL *string
+	solvesURL     *string
+	token         *string
+	score         metric.Float64ObservableGauge
+	rank          metric.Int64ObservableGauge
+	solves        metric.Int64ObservableGauge
+	value         metric.Float64ObservableGauge
+	firstBlood    metric.Float64ObservableGauge
+
+	scoreboard *cache.Value[[]ctfd.ScoreboardEntry]
+	challenges *cache.Value[[]ctfd.Challenge]
+
+	// first solves never change once they exist, so they are kept forever
+	firstBloods map[int64]ctfd.Solve
+}
+
+func New() CTFdExporter {
+	f := CTFdExporter{
+		Base:        exporterbase.New("ctfd", "CTFd"),
+		firstBloods: make(map[int64]ctfd.Solve),
+	}
+
+	f.baseURL = f.FS.String("base-url", "", "where is CTFd hosted? example: https://ctf.example.com")
+	f.scoreboardURL = f.FS.String("scoreboard-url", "", "scoreboard URL, falls back to baseUrl + /api/v1/scoreboard")
+	f.challengesURL = f.FS.String("challenges-url", "", "challenges URL, falls back to baseUrl + /api/v1/challenges")
+	f.solvesURL = f.FS.String("solves-url", "", "challenge solves URL, falls back to baseUrl + /api/v1/challenges/%d/solves")
+	f.token = f.FS.String("token", "", "CTFd access token (or env:NAME, file:/path), required if the scoreboard or challenges are not public")
+
+	return f
+}
+
+// Configure resolves the scoreboard, challenges and solves API URLs below
+// --base-url and sets up the caches of the first two. Solves are only fetched
+// until a challenge has its first blood. No metrics are registered, so that
+// validate and fetch can use it too.
+func (f *CTFdExporter) Configure(args []string) error {
+	if err := f.ParseFlags(args); err != nil {
+		return err
+	}
+
+	if *f.baseURL == "" && (*f.scoreboardURL == "" || *f.challengesURL == "" || *f.solvesURL == "") {
+		return fmt.Errorf("set --base-url, or set --scoreboard-url, --challenges-url and --solves-url")
+	}
+
+	if *f.baseURL != "" && *f.scoreboardURL == "" {
+		*f.scoreboardURL = *f.baseURL + "/api/v1/scoreboard"
+	}
+
+	if *f.baseURL != "" && *f.challengesURL == "" {
+		*f.challengesURL = *f.baseURL + "/api/v1/challenges"
+	}
+
+	if *f.baseURL != "" && *f.solvesURL == "" {
+		*f.solvesURL = *f.baseURL + "/api/v1/challenges/%d/solves"
+	}
+
+	if *f.token != "" {
+		token, err := httpclient.ReadSecret(*f.token)
+		if err != nil {
+			return fmt.Errorf("while reading token: %w", err)
+		}
+		*f.token = token
+	}
+
+	if err := f.NewClient(); err != nil {
+		return err
+	}
+
+	f.scoreboard = exporterbase.NewCache[[]ctfd.ScoreboardEntry](f.Base, "scoreboard")
+	f.challenges = exporterbase.NewCache[[]ctfd.Challenge](f.Base, "challenges")
+
+	return nil
+}
+
+// Init configures the exporter and registers the per-team points and rank
+// gauges and the per-challenge solves, value and first blood gauges.
+func (f *CTFdExporter) Init(args []string) error {
+	if err := f.Configure(args); err != nil {
+		return err
+	}
+
+	meter := otel.Meter(SCOPE_NAME)
+
+	score, err := meter.Float64ObservableGauge("scoreboard_points", metric.WithDescription("Total points. Faceted by team."), metric.WithFloat64Callback(f.GetScoreMetrics))
+
+	if err != nil {
+		return fmt.Errorf("while setting up points gauge: %w", err)
+	}
+
+	f.score = score
+
+	rank, err := meter.Int64ObservableGauge("scoreboard_rank", metric.WithDescription("Scoreboard position. Faceted by team."), metric.WithInt64Callback(f.GetRankMetrics))
+
+	if err != nil {
+		return fmt.Errorf("while setting up rank gauge: %w", err)
+	}
+
+	f.rank = rank
+
+	solves, err := meter.Int64ObservableGauge("scoreboard_challenge_solves", metric.WithDescription("Number of solves. Faceted by challenge and category."), metric.WithInt64Callback(f.GetSolvesMetrics))
+
+	if err != nil {
+		return fmt.Errorf("while setting up solves gauge: %w", err)
+	}
+
+	f.solves = solves
+
+	value, err := meter.Float64ObservableGauge("scoreboard_challenge_value", metric.WithDescription("Current points for solving. Faceted by challenge and category."), metric.WithFloat64Callback(f.GetValueMetrics))
+
+	if err != nil {
+		return fmt.Errorf("while setting up challenge value gauge: %w", err)
+	}
+
+	f.value = value
+
+	firstBlood, err := meter.Float64ObservableGauge("scoreboard_first_blood_timestamp_seconds", metric.WithDescription("Unix time of the first solve. Faceted by challenge, category and the team that solved it."), metric.WithFloat64Callback(f.GetFirstBloodMetrics))
+
+	if err != nil {
+		return fmt.Errorf("while setting up first blood gauge: %w", err)
+	}
+
+	f.firstBlood = firstBlood
+
+	return f.RegisterDataAge(meter)
+}
+
+func (f *CTFdExporter) GetScoreboard(ctx context.Context) ([]ctfd.ScoreboardEntry, error) {
+	return f.scoreboard.Get(ctx, func(ctx context.Context) ([]ctfd.ScoreboardEntry, error) {
+		return ctfd.LoadScoreboardJson(ctx, f.Client, *f.scoreboardURL, *f.token)
+	})
+}
+
+func (f *CTFdExporter) GetChallenges(ctx context.Context) ([]ctfd.Challenge, error) {
+	return f.challenges.Get(ctx, func(ctx context.Context) ([]ctfd.Challenge, error) {
+		return ctfd.LoadChallengesJson(ctx, f.Client, *f.challengesURL, *f.token)
+	})
+}
+
+// GetFirstBlood returns the first solve of a challenge, or nil if it has not
+// been solved yet.
+func (f *CTFdExporter) GetFirstBlood(ctx context.Context, challenge ctfd.Challenge) (*ctfd.Solve, error) {
+	if solve, ok := f.firstBloods[challenge.ID]; ok {
+		return &solve, nil
+	}
+	if challenge.Solves == 0 {
+		return nil, nil
+	}
+
+	data, err := ctfd.LoadSolvesJson(ctx, f.Client, *f.solvesURL, *f.token, challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	f.firstBloods[challenge.ID] = data[0]
+	return &data[0], nil
+}
+
+func (f *CTFdExporter) GetScoreMetrics(ctx context.Context, observer metric.Float64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetScoreboard(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading scoreboard: %w", err)
+	}
+
+	for _, team := range data {
+		observer.Observe(
+			team.Score,
+			metric.WithAttributes(
+				attribute.String("team", team.Name),
+			),
+		)
+	}
+	return nil
+}
+
+func (f *CTFdExporter) GetRankMetrics(ctx context.Context, observer metric.Int64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetScoreboard(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading scoreboard: %w", err)
+	}
+
+	for _, team := range data {
+		observer.Observe(
+			team.Position,
+			metric.WithAttributes(
+				attribute.String("team", team.Name),
+			),
+		)
+	}
+	return nil
+}
+
+func (f *CTFdExporter) GetSolvesMetrics(ctx context.Context, observer metric.Int64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetChallenges(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading challenges: %w", err)
+	}
+
+	for _, challenge := range data {
+		observer.Observe(
+			challenge.Solves,
+			metric.WithAttributes(
+				attribute.String("challenge", challenge.Name),
+				attribute.String("category", challenge.Category),
+			),
+		)
+	}
+	return nil
+}
+
+func (f *CTFdExporter) GetValueMetrics(ctx context.Context, observer metric.Float64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetChallenges(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading challenges: %w", err)
+	}
+
+	for _, challenge := range data {
+		observer.Observe(
+			challenge.Value,
+			metric.WithAttributes(
+				attribute.String("challenge", challenge.Name),
+				attribute.String("category", challenge.Category),
+			),
+		)
+	}
+	return nil
+}
+
+func (f *CTFdExporter) GetFirstBloodMetrics(ctx context.Context, observer metric.Float64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetChallenges(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading challenges: %w", err)
+	}
+
+	for _, challenge := range data {
+		solve, err := f.GetFirstBlood(ctx, challenge)
+		if errors.Is(err, httpclient.ErrNotFound) {
+			// hidden challenges have no public solves
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("while loading solves of %s: %w", challenge.Name, err)
+		}
+		if solve == nil {
+			continue
+		}
+		solvedAt, err := time.Parse(time.RFC3339Nano, solve.Date)
+		if err != nil {
+			logging.Warn(ctx, "cannot parse solve date", "date", solve.Date, "challenge", challenge.Name, "error", err)
+			continue
+		}
+		observer.Observe(
+			float64(solvedAt.UnixMilli())/1000,
+			metric.WithAttributes(
+				attribute.String("team", solve.Name),
+				attribute.String("challenge", challenge.Name),
+				attribute.String("category", challenge.Category),
+			),
+		)
+	}
+	return nil
+}
+
+// Refresh loads the scoreboard API into the cache that readiness is judged
+// by. Challenges are left to the scrapes.
+func (f *CTFdExporter) Refresh(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	_, err := f.GetScoreboard(ctx)
+	return err
+}
+
+// CheckSchema loads the scoreboard, the challenges and the solves of one
+// solved challenge, if there is one yet.
+func (f *CTFdExporter) CheckSchema(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	return exporterbase.CheckSchema(ctx,
+		func(ctx context.Context) error {
+			_, err := f.GetScoreboard(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			challenges, err := f.GetChallenges(ctx)
+			if err != nil {
+				return err
+			}
+			for _, challenge := range challenges {
+				if challenge.Solves > 0 {
+					_, err := ctfd.LoadSolvesJson(ctx, f.Client, *f.solvesURL, *f.token, challenge.ID)
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// Status reports the API URLs and caches. CTFd has no ticks, and solves are
+// not cached, as first bloods are kept forever.
+func (f *CTFdExporter) Status() status.Report {
+	return f.Report(f.scoreboard, -1,
+		status.URL{Name: "scoreboard", URL: *f.scoreboardURL},
+		status.URL{Name: "challenges", URL: *f.challengesURL},
+		status.URL{Name: "solves", URL: *f.solvesURL},
+	)
+}
+
+// Scoreboard returns the scoreboard in the backend-neutral format. CTFd has
+// no ticks and no per-team services.
+func (f *CTFdExporter) Scoreboard(ctx context.Context) (*scoreboard.Scoreboard, error) {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, err := f.GetScoreboard(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while loading scoreboard: %w", err)
+	}
+
+	sb := &scoreboard.Scoreboard{
+		Source:    "ctfd",
+		Tick:      -1,
+		FetchedAt: time.Now(),
+	}
+	for _, entry := range data {
+		sb.Teams = append(sb.Teams, scoreboard.Team{
+			Rank:   entry.Position,
+			ID:     strconv.FormatInt(entry.AccountID, 10),
+			Name:   entry.Name,
+			Points: entry.Score,
+		})
+	}
+
+	return sb, nil
+}
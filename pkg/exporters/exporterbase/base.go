@@ -0,0 +1,158 @@
+// Package exporterbase holds the plumbing that every scoreboard backend
+// shares: the flag set with the HTTP client flags and --max-staleness, the
+// document caches, the scoreboard_data_age_seconds gauge and the status
+// report built from those caches. The backends only add their own URLs,
+// documents and metrics on top.
+package exporterbase
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/envflag"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Bounds of the cache TTL. Within them, a document is reused for a quarter
+// of a tick: long enough that the gauges of one scrape share a single fetch,
+// short enough that every tick is seen.
+const (
+	minCacheTTL = 500 * time.Millisecond
+	maxCacheTTL = 10 * time.Second
+)
+
+// CacheTTL is how long a fetched document is reused in a game with the given
+// tick duration.
+func CacheTTL(tick time.Duration) time.Duration {
+	ttl := tick / 4
+	if ttl < minCacheTTL {
+		return minCacheTTL
+	}
+	if ttl > maxCacheTTL {
+		return maxCacheTTL
+	}
+	return ttl
+}
+
+// Base is embedded by every backend.
+type Base struct {
+	// FS holds the backend's flags, including the shared ones
+	FS         *flag.FlagSet
+	HTTPConfig *httpclient.Config
+	// Client is set up by NewClient
+	Client *http.Client
+
+	source       string
+	maxStaleness *time.Duration
+	ttl          time.Duration
+	caches       []cache.Aged
+}
+
+// New creates the flag set of a backend and registers the shared flags.
+// server names what is being scraped in the help text, e.g. "the
+// gameserver".
+func New(source string, server string) *Base {
+	b := &Base{
+		FS:         flag.NewFlagSet(source, flag.ContinueOnError),
+		HTTPConfig: &httpclient.Config{Source: source},
+		source:     source,
+		ttl:        maxCacheTTL,
+	}
+	b.maxStaleness = b.FS.Duration("max-staleness", 5*time.Minute, "keep serving the last good scoreboard for this long when "+server+" fails")
+	b.HTTPConfig.RegisterFlags(b.FS)
+	return b
+}
+
+// Source is the backend name, e.g. faustv2.
+func (b *Base) Source() string {
+	return b.source
+}
+
+// SetTickDuration derives the cache TTL from the length of a tick. It must be
+// called before the caches are created.
+func (b *Base) SetTickDuration(tick time.Duration) {
+	b.ttl = CacheTTL(tick)
+}
+
+// ParseFlags parses the backend's flags, from args and the environment.
+func (b *Base) ParseFlags(args []string) error {
+	return envflag.Parse(b.FS, args)
+}
+
+// NewClient sets up the HTTP client from the parsed flags. Protocols the
+// backend serves itself, like replay://, must be registered before, as the
+// client only knows the ones registered when it is created.
+func (b *Base) NewClient() error {
+	client, err := httpclient.New(*b.HTTPConfig)
+	if err != nil {
+		return fmt.Errorf("while setting up HTTP client: %w", err)
+	}
+	b.Client = client
+	return nil
+}
+
+// NewCache creates the cache of one document. It is included in the data
+// age gauge and the status report.
+func NewCache[T any](b *Base, name string) *cache.Value[T] {
+	c := cache.New[T](name, b.ttl, *b.maxStaleness)
+	b.caches = append(b.caches, c)
+	return c
+}
+
+// ScrapeContext bounds a metrics callback by the scrape timeout.
+func (b *Base) ScrapeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return b.HTTPConfig.ScrapeContext(ctx)
+}
+
+// RegisterDataAge registers scoreboard_data_age_seconds for every cache.
+func (b *Base) RegisterDataAge(meter metric.Meter) error {
+	_, err := meter.Float64ObservableGauge("scoreboard_data_age_seconds", metric.WithDescription("Seconds since the data was last fetched successfully. Faceted by document."), metric.WithFloat64Callback(b.observeDataAge))
+	if err != nil {
+		return fmt.Errorf("while setting up data age gauge: %w", err)
+	}
+	return nil
+}
+
+func (b *Base) observeDataAge(ctx context.Context, observer metric.Float64Observer) error {
+	cache.ObserveAge(observer, b.caches...)
+	return nil
+}
+
+// Report describes the backend for the status page: its URLs, every cache,
+// and snapshot, the cache that decides readiness.
+func (b *Base) Report(snapshot cache.Aged, tick int64, urls ...status.URL) status.Report {
+	return status.Report{
+		Source:   b.source,
+		URLs:     urls,
+		Snapshot: snapshot,
+		Caches:   append([]cache.Aged(nil), b.caches...),
+		Tick:     tick,
+	}
+}
+
+// CheckSchema runs every load, so that drift in any of the backend's
+// documents is logged, not only in the first one to fail. Schema drift is
+// returned in preference to other errors.
+func CheckSchema(ctx context.Context, loads ...func(context.Context) error) error {
+	var first, drift error
+	for _, load := range loads {
+		err := load(ctx)
+		if drift == nil && errors.Is(err, httpclient.ErrSchemaDrift) {
+			drift = err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if drift != nil {
+		return drift
+	}
+	return first
+}
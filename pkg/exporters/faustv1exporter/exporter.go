@@ -2,13 +2,17 @@ package faustv1exporter
 
 import (
 	"context"
-	"flag"
 	"fmt"
-	"log"
-	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/exporterbase"
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv1"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/services"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -16,8 +20,10 @@ import (
 
 const SCOPE_NAME string = "faustv1_exporter"
 
+// FaustV1Exporter exports the scoreboard.json of older ctf-gameservers. The
+// service names come from a separate status.json.
 type FaustV1Exporter struct {
-	fs            *flag.FlagSet
+	*exporterbase.Base
 	baseURL       *string
 	scoreboardURL *string
 	statusURL     *string
@@ -26,34 +32,46 @@ type FaustV1Exporter struct {
 	sla           metric.Float64ObservableGauge
 	tick          metric.Int64ObservableGauge
 
-	lastScoreboard   *faustv1.ScoreboardJson
-	lastScoreboardAt time.Time
+	scoreboard *cache.Value[*faustv1.ScoreboardJson]
+	status     *cache.Value[*faustv1.StatusJson]
 
-	lastStatus   *faustv1.StatusJson
-	lastStatusAt time.Time
+	snapshotMu *sync.Mutex
+	snapshot   *snapshot
+}
+
+// snapshot is a scoreboard.json mapped onto the service list of a status.json,
+// holding only the team rows that fit it. The two documents are fetched
+// separately, so they may disagree.
+type snapshot struct {
+	scoreboard *faustv1.ScoreboardJson
+	status     *faustv1.StatusJson
+	services   *services.Index
+	teams      []faustv1.ScoreboardJsonTeam
 }
 
 func New() FaustV1Exporter {
 	f := FaustV1Exporter{
-		fs: flag.NewFlagSet("faustv1", flag.ContinueOnError),
+		Base:       exporterbase.New("faustv1", "the gameserver"),
+		snapshotMu: new(sync.Mutex),
 	}
 
-	f.baseURL = f.fs.String("base-url", "", "where is the ctf-gameserver hosted? example: http://localhost:5101")
-	f.scoreboardURL = f.fs.String("scoreboard-url", "", "scoreboard.json URL, falls back to baseUrl + /competition/scoreboard.json")
-	f.statusURL = f.fs.String("status-url", "", "status.json URL, falls back to baseUrl + /competition/status.json")
+	f.baseURL = f.FS.String("base-url", "", "where is the ctf-gameserver hosted? example: http://localhost:5101")
+	f.scoreboardURL = f.FS.String("scoreboard-url", "", "scoreboard.json URL, falls back to baseUrl + /competition/scoreboard.json")
+	f.statusURL = f.FS.String("status-url", "", "status.json URL, falls back to baseUrl + /competition/status.json")
 
 	return f
 }
 
-func (f *FaustV1Exporter) Init(args []string) error {
-	err := f.fs.Parse(args)
-
-	if err == flag.ErrHelp {
-		os.Exit(0)
+// Configure resolves the scoreboard.json and status.json URLs, which default
+// to the standard paths below --base-url, and sets up their caches. No
+// metrics are registered, so that validate and fetch can use it too.
+func (f *FaustV1Exporter) Configure(args []string) error {
+	if err := f.ParseFlags(args); err != nil {
+		return err
 	}
 
 	if *f.baseURL == "" && *f.scoreboardURL == "" && *f.statusURL == "" {
-		log.Fatalf("set baseUrl, or set scoreboardUrl and statusUrl")
+		return fmt.Errorf("set baseUrl, or set scoreboardUrl and statusUrl")
 	}
 
 	if *f.baseURL != "" && *f.scoreboardURL == "" {
@@ -64,6 +82,23 @@ func (f *FaustV1Exporter) Init(args []string) error {
 		*f.statusURL = *f.baseURL + "/competition/status.json"
 	}
 
+	if err := f.NewClient(); err != nil {
+		return err
+	}
+
+	f.scoreboard = exporterbase.NewCache[*faustv1.ScoreboardJson](f.Base, "scoreboard")
+	f.status = exporterbase.NewCache[*faustv1.StatusJson](f.Base, "status")
+
+	return nil
+}
+
+// Init configures the exporter and registers the per-team, per-service
+// offense, defense and SLA gauges. Faust v1 has no flag counts.
+func (f *FaustV1Exporter) Init(args []string) error {
+	if err := f.Configure(args); err != nil {
+		return err
+	}
+
 	meter := otel.Meter(SCOPE_NAME)
 
 	offense, err := meter.Float64ObservableGauge("scoreboard_offense", metric.WithDescription("Offense points. Faceted by service and team."), metric.WithFloat64Callback(f.GetOffenseMetrics))
@@ -98,80 +133,79 @@ func (f *FaustV1Exporter) Init(args []string) error {
 
 	f.tick = tick
 
-	return nil
+	return f.RegisterDataAge(meter)
 }
 
-func (f *FaustV1Exporter) GetTeams() (*faustv1.ScoreboardJson, error) {
-	now := time.Now()
-	if f.lastScoreboard != nil && f.lastScoreboardAt.Add(10*time.Second).After(now) {
-		log.Printf("using cached scoreboard for 10 seconds")
-		return f.lastScoreboard, nil
-	}
+func (f *FaustV1Exporter) GetTeams(ctx context.Context) (*faustv1.ScoreboardJson, error) {
+	return f.scoreboard.Get(ctx, func(ctx context.Context) (*faustv1.ScoreboardJson, error) {
+		return faustv1.LoadScoreboardJson(ctx, f.Client, *f.scoreboardURL)
+	})
+}
 
-	scoreboard, err := faustv1.LoadScoreboardJson(*f.scoreboardURL)
+func (f *FaustV1Exporter) GetStatus(ctx context.Context) (*faustv1.StatusJson, error) {
+	return f.status.Get(ctx, func(ctx context.Context) (*faustv1.StatusJson, error) {
+		return faustv1.LoadStatusJson(ctx, f.Client, *f.statusURL)
+	})
+}
 
+// GetSnapshot returns the scoreboard, mapped onto the services of status.json.
+func (f *FaustV1Exporter) GetSnapshot(ctx context.Context) (*snapshot, error) {
+	scoreboard, err := f.GetTeams(ctx)
 	if err != nil {
-		return nil, err
-	} else {
-		f.lastScoreboard = scoreboard
-		f.lastScoreboardAt = time.Now()
+		return nil, fmt.Errorf("while loading teams: %w", err)
 	}
 
-	return scoreboard, nil
-}
-
-func (f *FaustV1Exporter) GetServiceNamesByIndex() ([]string, error) {
-	now := time.Now()
-	if f.lastStatus != nil && f.lastStatusAt.Add(10*time.Second).After(now) {
-		log.Printf("using cached status.json for 10 seconds")
-		return f.lastStatus.Services, nil
+	status, err := f.GetStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while loading service names: %w", err)
 	}
 
-	data, err := faustv1.LoadStatusJson(*f.statusURL)
-	if err != nil {
-		return nil, err
-	} else {
-		f.lastStatus = data
-		f.lastStatusAt = time.Now()
+	f.snapshotMu.Lock()
+	defer f.snapshotMu.Unlock()
+	if f.snapshot != nil && f.snapshot.scoreboard == scoreboard && f.snapshot.status == status {
+		return f.snapshot, nil
 	}
-	return data.Services, nil
-}
 
-func (f *FaustV1Exporter) GetTick() (int64, error) {
-	now := time.Now()
-	if f.lastScoreboard != nil && f.lastScoreboardAt.Add(10*time.Second).After(now) {
-		log.Printf("using cached scoreboard for 10 seconds")
-		return f.lastScoreboard.Tick, nil
+	snap := &snapshot{
+		scoreboard: scoreboard,
+		status:     status,
+		services:   services.New("scoreboard", status.Services),
 	}
+	for _, team := range scoreboard.Teams {
+		if snap.services.Fits(len(team.Services)) {
+			snap.teams = append(snap.teams, team)
+		}
+	}
+	snap.services.Report(ctx)
+
+	f.snapshot = snap
+	return snap, nil
+}
 
-	data, err := faustv1.LoadScoreboardJson(*f.scoreboardURL)
+func (f *FaustV1Exporter) GetTick(ctx context.Context) (int64, error) {
+	data, err := f.GetTeams(ctx)
 	if err != nil {
 		return -1, err
-	} else {
-		f.lastScoreboard = data
-		f.lastScoreboardAt = time.Now()
 	}
 	return data.Tick, nil
 }
 
 func (f *FaustV1Exporter) GetOffenseMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetTeams()
-	if err != nil {
-		return fmt.Errorf("while loading teams: %w", err)
-	}
-	svcNames, err := f.GetServiceNamesByIndex()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
-		return fmt.Errorf("while loading service names: %w", err)
+		return err
 	}
 
-	for _, team := range data.Teams {
-		svc := team.Services
-		for idx, service := range svc {
+	for _, team := range snap.teams {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Offense,
 				metric.WithAttributes(
 					attribute.String("team", team.Name),
-					attribute.String("service", svcNames[idx]),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -180,24 +214,21 @@ func (f *FaustV1Exporter) GetOffenseMetrics(ctx context.Context, observer metric
 }
 
 func (f *FaustV1Exporter) GetDefenseMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetTeams()
-	if err != nil {
-		return fmt.Errorf("while loading teams: %w", err)
-	}
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
 
-	svcNames, err := f.GetServiceNamesByIndex()
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
-		return fmt.Errorf("while loading service names: %w", err)
+		return err
 	}
 
-	for _, team := range data.Teams {
-		svc := team.Services
-		for idx, service := range svc {
+	for _, team := range snap.teams {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Defense,
 				metric.WithAttributes(
 					attribute.String("team", team.Name),
-					attribute.String("service", svcNames[idx]),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -206,24 +237,21 @@ func (f *FaustV1Exporter) GetDefenseMetrics(ctx context.Context, observer metric
 }
 
 func (f *FaustV1Exporter) GetSLAMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetTeams()
-	if err != nil {
-		return fmt.Errorf("while loading teams: %w", err)
-	}
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
 
-	svcNames, err := f.GetServiceNamesByIndex()
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
-		return fmt.Errorf("while loading service names: %w", err)
+		return err
 	}
 
-	for _, team := range data.Teams {
-		svc := team.Services
-		for idx, service := range svc {
+	for _, team := range snap.teams {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.SLA,
 				metric.WithAttributes(
 					attribute.String("team", team.Name),
-					attribute.String("service", svcNames[idx]),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -232,7 +260,10 @@ func (f *FaustV1Exporter) GetSLAMetrics(ctx context.Context, observer metric.Flo
 }
 
 func (f *FaustV1Exporter) GetTickMetrics(ctx context.Context, observer metric.Int64Observer) error {
-	tick, err := f.GetTick()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	tick, err := f.GetTick(ctx)
 	if err != nil {
 		return fmt.Errorf("while getting tick: %w", err)
 	}
@@ -240,3 +271,83 @@ func (f *FaustV1Exporter) GetTickMetrics(ctx context.Context, observer metric.In
 	observer.Observe(tick)
 	return nil
 }
+
+// Refresh loads scoreboard.json and status.json into their caches.
+func (f *FaustV1Exporter) Refresh(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	_, err := f.GetSnapshot(ctx)
+	return err
+}
+
+// CheckSchema loads scoreboard.json and status.json. Refresh already needs
+// both.
+func (f *FaustV1Exporter) CheckSchema(ctx context.Context) error {
+	return f.Refresh(ctx)
+}
+
+// Status reports both documents. Readiness goes by scoreboard.json, which
+// also carries the tick; status.json rarely changes.
+func (f *FaustV1Exporter) Status() status.Report {
+	tick := int64(-1)
+	if scoreboard, ok := f.scoreboard.Peek(); ok {
+		tick = scoreboard.Tick
+	}
+
+	return f.Report(f.scoreboard, tick,
+		status.URL{Name: "scoreboard", URL: *f.scoreboardURL},
+		status.URL{Name: "status", URL: *f.statusURL},
+	)
+}
+
+// Scoreboard returns the scoreboard in the backend-neutral format. Faust v1
+// has no per-tick deltas.
+func (f *FaustV1Exporter) Scoreboard(ctx context.Context) (*scoreboard.Scoreboard, error) {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := snap.scoreboard.StatusDescriptions
+	if len(descriptions) == 0 {
+		descriptions = snap.status.StatusDescriptions
+	}
+
+	sb := &scoreboard.Scoreboard{
+		Source:    "faustv1",
+		Tick:      snap.scoreboard.Tick,
+		FetchedAt: time.Now(),
+	}
+	for idx := 0; idx < snap.services.Len(); idx++ {
+		sb.Services = append(sb.Services, scoreboard.Service{Name: snap.services.Name(idx)})
+	}
+
+	for _, team := range snap.teams {
+		t := scoreboard.Team{
+			Rank:    team.Rank,
+			ID:      strconv.FormatInt(team.ID, 10),
+			Name:    team.Name,
+			Points:  team.Total,
+			Offense: team.Offense,
+			Defense: team.Defense,
+			SLA:     team.SLA,
+		}
+		for idx, service := range team.Services {
+			t.Services = append(t.Services, scoreboard.TeamService{
+				Service:    snap.services.Name(idx),
+				Status:     scoreboard.StatusName(descriptions, service.Status),
+				StatusCode: service.Status,
+				Offense:    service.Offense,
+				Defense:    service.Defense,
+				SLA:        service.SLA,
+			})
+		}
+		sb.Teams = append(sb.Teams, t)
+	}
+
+	return sb, nil
+}
@@ -0,0 +1,69 @@
+package faustv1exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// scoreboard.json and status.json fetched at different times, with a service
+// added in between: status.json already lists it, some team rows not yet.
+func TestSnapshotSkipsMismatchedTeams(t *testing.T) {
+	service := `{"status": 0, "offense": 1, "defense": 1, "sla": 1}`
+	team := func(id int, name string, services int) string {
+		return fmt.Sprintf(`{"rank": %d, "id": %d, "name": %q, "offense": 1, "defense": 1, "sla": 1, "total": 3, "image": null, "thumbnail": null, "services": [%s]}`,
+			id, id, name, strings.TrimSuffix(strings.Repeat(service+",", services), ","))
+	}
+	documents := map[string]string{
+		"scoreboard.json": `{"tick": 7, "status-descriptions": {"0": "up"}, "teams": [` +
+			team(1, "alpha", 3) + `, ` + team(2, "beta", 2) + `, ` + team(3, "gamma", 3) + `]}`,
+		"status.json": `{"ticks": [7], "status-descriptions": {"0": "up"}, "services": ["web", "web", ""], "teams": []}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if doc, ok := documents[path.Base(r.URL.Path)]; ok {
+			fmt.Fprint(w, doc)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	f := New()
+	if err := f.Configure([]string{"--base-url", server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	sb, err := f.Scoreboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var teams []string
+	for _, team := range sb.Teams {
+		teams = append(teams, team.Name)
+	}
+	if want := []string{"alpha", "gamma"}; !reflect.DeepEqual(teams, want) {
+		t.Errorf("teams = %q, want %q without the row that has two services", teams, want)
+	}
+
+	var services []string
+	for _, service := range sb.Services {
+		services = append(services, service.Name)
+	}
+	if want := []string{"web", "web_1", "2"}; !reflect.DeepEqual(services, want) {
+		t.Errorf("services = %q, want %q", services, want)
+	}
+}
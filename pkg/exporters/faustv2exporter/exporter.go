@@ -2,13 +2,21 @@ package faustv2exporter
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/exporterbase"
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv2"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/replay"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/services"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -16,52 +24,94 @@ import (
 
 const SCOPE_NAME string = "faustv2_exporter"
 
+// FaustV2Exporter exports the scoreboard-v2 API of current ctf-gameservers:
+// one document per round, with flag counts and per-tick deltas, announced by
+// scoreboard_current.json.
 type FaustV2Exporter struct {
-	fs                 *flag.FlagSet
+	*exporterbase.Base
 	baseURL            *string
 	currentURL         *string
 	scoreboardRoundUrl *string
 	teamsURL           *string
+	replayDir          *string
+	replaySpeed        *float64
+	replayTick         *time.Duration
 	offense            metric.Float64ObservableGauge
 	defense            metric.Float64ObservableGauge
 	sla                metric.Float64ObservableGauge
 	captures           metric.Int64ObservableGauge
 	stolen             metric.Int64ObservableGauge
 	tick               metric.Int64ObservableGauge
+	roundLag           metric.Int64ObservableGauge
 
-	lastCurrent   *faustv2.CurrentJson
-	lastCurrentAt time.Time
+	current *cache.Value[*faustv2.CurrentJson]
+	teams   *cache.Value[faustv2.ScoreboardTeamsJson]
+	round   *cache.Value[*faustv2.ScoreboardRoundJson]
 
-	lastTeams   faustv2.ScoreboardTeamsJson
-	lastTeamsAt time.Time
+	// newest round fetched so far, to fall back on while the announced
+	// round is not published yet
+	roundMu      *sync.Mutex
+	newestRound  *faustv2.ScoreboardRoundJson
+	retryingTick int64
 
-	lastRoundTick int64
-	lastRound     *faustv2.ScoreboardRoundJson
-	lastRoundAt   time.Time
+	// the current round mapped onto its services, also guarded by roundMu
+	snapshot *snapshot
 }
 
+// snapshot is one round with its service index, holding only the team rows
+// that fit the index.
+type snapshot struct {
+	round    *faustv2.ScoreboardRoundJson
+	services *services.Index
+	teams    []faustv2.ScoreboardV2Team
+}
+
+// how often, and how many times, an announced but missing round is fetched
+// again in the background; variables so that tests need not wait
+var (
+	roundRetryInterval = 2 * time.Second
+	roundRetryAttempts = 30
+)
+
+// how many rounds back to look when nothing has been fetched yet
+const roundFallbackDepth = 3
+
 func New() FaustV2Exporter {
 	f := FaustV2Exporter{
-		fs: flag.NewFlagSet("faustv2", flag.ContinueOnError),
+		Base:    exporterbase.New("faustv2", "the gameserver"),
+		roundMu: new(sync.Mutex),
 	}
 
-	f.baseURL = f.fs.String("base-url", "", "where is the ctf-gameserver hosted? example: http://localhost:5101")
-	f.currentURL = f.fs.String("current-url", "", "current.json URL, defaults to baseUrl + /competition/scoreboard-v2/scoreboard_current.json")
-	f.scoreboardRoundUrl = f.fs.String("round-url", "", "round URL, falls back to baseUrl + /competition/scoreboard-v2/scoreboard_round_%d.json")
-	f.teamsURL = f.fs.String("teams-url", "", "teams.json URL, falls back to baseUrl + /competition/scoreboard-v2/scoreboard_teams.json")
+	f.baseURL = f.FS.String("base-url", "", "where is the ctf-gameserver hosted? example: http://localhost:5101")
+	f.currentURL = f.FS.String("current-url", "", "current.json URL, defaults to baseUrl + /competition/scoreboard-v2/scoreboard_current.json")
+	f.scoreboardRoundUrl = f.FS.String("round-url", "", "round URL, falls back to baseUrl + /competition/scoreboard-v2/scoreboard_round_%d.json")
+	f.teamsURL = f.FS.String("teams-url", "", "teams.json URL, falls back to baseUrl + /competition/scoreboard-v2/scoreboard_teams.json")
+	f.replayDir = f.FS.String("replay-dir", "", "play back recorded scoreboard_round_N.json files from this directory instead of fetching from a gameserver")
+	f.replaySpeed = f.FS.Float64("replay-speed", 1, "replay speed-up, e.g. 60 plays back one recorded tick every 3 seconds")
+	f.replayTick = f.FS.Duration("replay-tick-duration", 3*time.Minute, "length of a tick in the recorded game")
 
 	return f
 }
 
-func (f *FaustV2Exporter) Init(args []string) error {
-	err := f.fs.Parse(args)
+// Configure resolves the current, round and teams URLs below --base-url, or
+// serves them from a --replay-dir, and sets up their caches. No metrics are
+// registered, so that validate and fetch can use it too.
+func (f *FaustV2Exporter) Configure(args []string) error {
+	if err := f.ParseFlags(args); err != nil {
+		return err
+	}
 
-	if err == flag.ErrHelp {
-		os.Exit(0)
+	if *f.replayDir != "" {
+		r, err := replay.New(*f.replayDir, *f.replayTick, *f.replaySpeed)
+		if err != nil {
+			return fmt.Errorf("while setting up replay: %w", err)
+		}
+		httpclient.RegisterProtocol(replay.Scheme, r)
+		*f.baseURL = replay.Scheme + "://" + *f.replayDir
 	}
 
 	if *f.baseURL == "" && *f.scoreboardRoundUrl == "" && *f.currentURL == "" && *f.teamsURL == "" {
-		log.Fatalf("set --base-url, or set --round-url and --current-url")
+		return fmt.Errorf("set --base-url, or set --round-url and --current-url")
 	}
 
 	if *f.baseURL != "" && *f.currentURL == "" {
@@ -76,6 +126,25 @@ func (f *FaustV2Exporter) Init(args []string) error {
 		*f.teamsURL = *f.baseURL + "/competition/scoreboard-v2/scoreboard_teams.json"
 	}
 
+	if err := f.NewClient(); err != nil {
+		return err
+	}
+
+	f.current = exporterbase.NewCache[*faustv2.CurrentJson](f.Base, "current")
+	f.teams = exporterbase.NewCache[faustv2.ScoreboardTeamsJson](f.Base, "teams")
+	f.round = exporterbase.NewCache[*faustv2.ScoreboardRoundJson](f.Base, "round")
+
+	return nil
+}
+
+// Init configures the exporter and registers the per-team, per-service
+// offense, defense, SLA, captures and stolen gauges, the tick, and how far
+// the exported round lags behind the announced one.
+func (f *FaustV2Exporter) Init(args []string) error {
+	if err := f.Configure(args); err != nil {
+		return err
+	}
+
 	meter := otel.Meter(SCOPE_NAME)
 
 	offense, err := meter.Float64ObservableGauge("scoreboard_offense", metric.WithDescription("Offense points. Faceted by service and team."), metric.WithFloat64Callback(f.GetOffenseMetrics))
@@ -126,85 +195,179 @@ func (f *FaustV2Exporter) Init(args []string) error {
 
 	f.stolen = stolen
 
-	return nil
-}
+	roundLag, err := meter.Int64ObservableGauge("scoreboard_round_lag_ticks", metric.WithDescription("How many ticks the exported round is behind the scoreboard tick announced by current.json."), metric.WithInt64Callback(f.GetRoundLagMetrics))
 
-func (f *FaustV2Exporter) GetRound() (*faustv2.ScoreboardRoundJson, error) {
-	tick, err := f.GetTick()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("while setting up round lag gauge: %w", err)
 	}
 
-	now := time.Now()
-	if f.lastRound != nil && f.lastRoundAt.Add(10*time.Second).After(now) {
-		log.Printf("using cached round data from tick %d", f.lastRound.Tick)
-		return f.lastRound, nil
-	}
+	f.roundLag = roundLag
 
-	data, err := faustv2.LoadScoreboardRoundJson(*f.scoreboardRoundUrl, tick)
+	return f.RegisterDataAge(meter)
+}
 
-	if err != nil {
-		return nil, err
-	} else {
-		f.lastRound = data
-		f.lastRoundAt = time.Now()
-		f.lastRoundTick = tick
-		return data, nil
+// GetRound returns the round announced by current.json. During tick rollover
+// that file may be missing or half-written, so the newest round available is
+// returned instead while the announced one is retried in the background.
+func (f *FaustV2Exporter) GetRound(ctx context.Context) (*faustv2.ScoreboardRoundJson, error) {
+	return f.round.Get(ctx, func(ctx context.Context) (*faustv2.ScoreboardRoundJson, error) {
+		tick, err := f.GetTick(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := faustv2.LoadScoreboardRoundJson(ctx, f.Client, *f.scoreboardRoundUrl, tick)
+		if err == nil {
+			f.setNewestRound(data)
+			return data, nil
+		}
+		if !errors.Is(err, httpclient.ErrNotYetPublished) && !errors.Is(err, httpclient.ErrDecode) {
+			return nil, err
+		}
+
+		fallback := f.fallbackRound(ctx, tick)
+		if fallback == nil {
+			return nil, err
+		}
+		logging.Info(ctx, "announced round is not available yet, using an older one", "tick", tick, "fallback_tick", fallback.Tick, "error", err)
+		f.retryRoundInBackground(tick)
+		return fallback, nil
+	})
+}
+
+func (f *FaustV2Exporter) setNewestRound(data *faustv2.ScoreboardRoundJson) {
+	f.roundMu.Lock()
+	defer f.roundMu.Unlock()
+	if f.newestRound == nil || data.Tick >= f.newestRound.Tick {
+		f.newestRound = data
 	}
 }
 
-func (f *FaustV2Exporter) GetTeams() (faustv2.ScoreboardTeamsJson, error) {
-	now := time.Now()
-	if f.lastTeams != nil && f.lastTeamsAt.Add(10*time.Second).After(now) {
-		return f.lastTeams, nil
+// fallbackRound returns the newest round older than tick, from memory or
+// else from the gameserver.
+func (f *FaustV2Exporter) fallbackRound(ctx context.Context, tick int64) *faustv2.ScoreboardRoundJson {
+	f.roundMu.Lock()
+	newest := f.newestRound
+	f.roundMu.Unlock()
+	if newest != nil && newest.Tick < tick {
+		return newest
+	}
+
+	for previous := tick - 1; previous >= 0 && previous >= tick-roundFallbackDepth; previous-- {
+		data, err := faustv2.LoadScoreboardRoundJson(ctx, f.Client, *f.scoreboardRoundUrl, previous)
+		if err == nil {
+			f.setNewestRound(data)
+			return data
+		}
 	}
-	data, err := faustv2.LoadTeamsJson(*f.teamsURL)
+	return nil
+}
+
+// retryRoundInBackground keeps fetching an announced round until it shows up
+// or a newer round is announced, and then replaces the cached fallback.
+func (f *FaustV2Exporter) retryRoundInBackground(tick int64) {
+	f.roundMu.Lock()
+	if f.retryingTick >= tick {
+		f.roundMu.Unlock()
+		return
+	}
+	f.retryingTick = tick
+	f.roundMu.Unlock()
+
+	go func() {
+		for attempt := 0; attempt < roundRetryAttempts; attempt++ {
+			time.Sleep(roundRetryInterval)
+
+			if current, ok := f.current.Peek(); ok && current.ScoreboardTick > tick {
+				return
+			}
+
+			ctx, cancel := f.ScrapeContext(context.Background())
+			ctx = logging.With(ctx, "tick", tick)
+			data, err := faustv2.LoadScoreboardRoundJson(ctx, f.Client, *f.scoreboardRoundUrl, tick)
+			cancel()
+			if err == nil {
+				logging.Info(ctx, "announced round is published now")
+				f.setNewestRound(data)
+				f.round.Set(data)
+				return
+			}
+		}
+		logging.Warn(logging.With(context.Background(), "source", "faustv2", "tick", tick), "gave up waiting for announced round")
+	}()
+}
+
+// GetSnapshot returns the current round, mapped onto its service list.
+func (f *FaustV2Exporter) GetSnapshot(ctx context.Context) (*snapshot, error) {
+	round, err := f.GetRound(ctx)
 	if err != nil {
 		return nil, err
-	} else {
-		f.lastTeams = data
-		f.lastTeamsAt = time.Now()
-		return data, nil
 	}
-}
 
-func (f *FaustV2Exporter) GetTick() (int64, error) {
-	now := time.Now()
-	if f.lastCurrent != nil && f.lastCurrentAt.Add(10*time.Second).After(now) {
-		log.Printf("cached tick is %d", f.lastCurrent.ScoreboardTick)
-		return f.lastCurrent.ScoreboardTick, nil
+	f.roundMu.Lock()
+	defer f.roundMu.Unlock()
+	if f.snapshot != nil && f.snapshot.round == round {
+		return f.snapshot, nil
 	}
 
-	data, err := faustv2.LoadCurrentJson(*f.currentURL)
+	names := make([]string, len(round.Services))
+	for idx, service := range round.Services {
+		names[idx] = service.Name
+	}
+
+	snap := &snapshot{
+		round:    round,
+		services: services.New("round", names),
+	}
+	for _, team := range round.Scoreboard {
+		if snap.services.Fits(len(team.Services)) {
+			snap.teams = append(snap.teams, team)
+		}
+	}
+	snap.services.Report(ctx)
+
+	f.snapshot = snap
+	return snap, nil
+}
+
+func (f *FaustV2Exporter) GetTeams(ctx context.Context) (faustv2.ScoreboardTeamsJson, error) {
+	return f.teams.Get(ctx, func(ctx context.Context) (faustv2.ScoreboardTeamsJson, error) {
+		return faustv2.LoadTeamsJson(ctx, f.Client, *f.teamsURL)
+	})
+}
+
+func (f *FaustV2Exporter) GetTick(ctx context.Context) (int64, error) {
+	data, err := f.current.Get(ctx, func(ctx context.Context) (*faustv2.CurrentJson, error) {
+		return faustv2.LoadCurrentJson(ctx, f.Client, *f.currentURL)
+	})
 	if err != nil {
 		return -1, err
-	} else {
-		f.lastCurrent = data
-		f.lastCurrentAt = time.Now()
 	}
 	return data.ScoreboardTick, nil
 }
 
 func (f *FaustV2Exporter) GetOffenseMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetRound()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading scoreboard: %w", err)
 	}
 
-	teams, err := f.GetTeams()
+	teams, err := f.GetTeams(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading teams: %w", err)
 	}
 
-	for _, team := range data.Scoreboard {
-		svc := team.Services
+	for _, team := range snap.teams {
 		teamName := teams[team.ID].Name
-		for idx, service := range svc {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Offense,
 				metric.WithAttributes(
 					attribute.String("team", teamName),
-					attribute.String("service", data.Services[idx].Name),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -213,25 +376,27 @@ func (f *FaustV2Exporter) GetOffenseMetrics(ctx context.Context, observer metric
 }
 
 func (f *FaustV2Exporter) GetDefenseMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetRound()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading scoreboard: %w", err)
 	}
 
-	teams, err := f.GetTeams()
+	teams, err := f.GetTeams(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading teams: %w", err)
 	}
 
-	for _, team := range data.Scoreboard {
-		svc := team.Services
+	for _, team := range snap.teams {
 		teamName := teams[team.ID].Name
-		for idx, service := range svc {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Defense,
 				metric.WithAttributes(
 					attribute.String("team", teamName),
-					attribute.String("service", data.Services[idx].Name),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -240,25 +405,27 @@ func (f *FaustV2Exporter) GetDefenseMetrics(ctx context.Context, observer metric
 }
 
 func (f *FaustV2Exporter) GetSLAMetrics(ctx context.Context, observer metric.Float64Observer) error {
-	data, err := f.GetRound()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading scoreboard: %w", err)
 	}
 
-	teams, err := f.GetTeams()
+	teams, err := f.GetTeams(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading teams: %w", err)
 	}
 
-	for _, team := range data.Scoreboard {
-		svc := team.Services
+	for _, team := range snap.teams {
 		teamName := teams[team.ID].Name
-		for idx, service := range svc {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.SLA,
 				metric.WithAttributes(
 					attribute.String("team", teamName),
-					attribute.String("service", data.Services[idx].Name),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -267,25 +434,27 @@ func (f *FaustV2Exporter) GetSLAMetrics(ctx context.Context, observer metric.Flo
 }
 
 func (f *FaustV2Exporter) GetCaptureMetrics(ctx context.Context, observer metric.Int64Observer) error {
-	data, err := f.GetRound()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading scoreboard: %w", err)
 	}
 
-	teams, err := f.GetTeams()
+	teams, err := f.GetTeams(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading teams: %w", err)
 	}
 
-	for _, team := range data.Scoreboard {
-		svc := team.Services
+	for _, team := range snap.teams {
 		teamName := teams[team.ID].Name
-		for idx, service := range svc {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Captures,
 				metric.WithAttributes(
 					attribute.String("team", teamName),
-					attribute.String("service", data.Services[idx].Name),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -294,24 +463,26 @@ func (f *FaustV2Exporter) GetCaptureMetrics(ctx context.Context, observer metric
 }
 
 func (f *FaustV2Exporter) GetStolenMetrics(ctx context.Context, observer metric.Int64Observer) error {
-	data, err := f.GetRound()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading scoreboard: %w", err)
 	}
 
-	teams, err := f.GetTeams()
+	teams, err := f.GetTeams(ctx)
 	if err != nil {
 		return fmt.Errorf("while loading teams: %w", err)
 	}
-	for _, team := range data.Scoreboard {
-		svc := team.Services
+	for _, team := range snap.teams {
 		teamName := teams[team.ID].Name
-		for idx, service := range svc {
+		for idx, service := range team.Services {
 			observer.Observe(
 				service.Stolen,
 				metric.WithAttributes(
 					attribute.String("team", teamName),
-					attribute.String("service", data.Services[idx].Name),
+					attribute.String("service", snap.services.Name(idx)),
 				),
 			)
 		}
@@ -320,7 +491,10 @@ func (f *FaustV2Exporter) GetStolenMetrics(ctx context.Context, observer metric.
 }
 
 func (f *FaustV2Exporter) GetTickMetrics(ctx context.Context, observer metric.Int64Observer) error {
-	tick, err := f.GetTick()
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	tick, err := f.GetTick(ctx)
 	if err != nil {
 		return fmt.Errorf("while getting tick: %w", err)
 	}
@@ -328,3 +502,135 @@ func (f *FaustV2Exporter) GetTickMetrics(ctx context.Context, observer metric.In
 	observer.Observe(tick)
 	return nil
 }
+
+func (f *FaustV2Exporter) GetRoundLagMetrics(ctx context.Context, observer metric.Int64Observer) error {
+	current, ok := f.current.Peek()
+	if !ok {
+		return nil
+	}
+	round, ok := f.round.Peek()
+	if !ok {
+		return nil
+	}
+
+	observer.Observe(current.ScoreboardTick - round.Tick)
+	return nil
+}
+
+// Refresh loads the announced round, or the fallback round while it is not
+// published, into the cache that readiness is judged by.
+func (f *FaustV2Exporter) Refresh(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	_, err := f.GetSnapshot(ctx)
+	return err
+}
+
+// CheckSchema loads current.json, the round and the team list, which
+// Refresh leaves to the scrapes, so that drift in any of them shows at
+// startup.
+func (f *FaustV2Exporter) CheckSchema(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	return exporterbase.CheckSchema(ctx,
+		func(ctx context.Context) error {
+			_, err := f.GetSnapshot(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			_, err := f.GetTeams(ctx)
+			return err
+		},
+	)
+}
+
+// Status reports the three documents. The tick is the one announced by
+// scoreboard_current.json, which may be ahead of the exported round;
+// readiness goes by the round.
+func (f *FaustV2Exporter) Status() status.Report {
+	tick := int64(-1)
+	if current, ok := f.current.Peek(); ok {
+		tick = current.ScoreboardTick
+	}
+
+	return f.Report(f.round, tick,
+		status.URL{Name: "current", URL: *f.currentURL},
+		status.URL{Name: "round", URL: *f.scoreboardRoundUrl},
+		status.URL{Name: "teams", URL: *f.teamsURL},
+	)
+}
+
+// Scoreboard returns the current round in the backend-neutral format.
+func (f *FaustV2Exporter) Scoreboard(ctx context.Context) (*scoreboard.Scoreboard, error) {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	snap, err := f.GetSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while loading scoreboard: %w", err)
+	}
+
+	teams, err := f.GetTeams(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while loading teams: %w", err)
+	}
+
+	round := snap.round
+	sb := &scoreboard.Scoreboard{
+		Source:    "faustv2",
+		Tick:      round.Tick,
+		FetchedAt: time.Now(),
+	}
+	if current, ok := f.current.Peek(); ok && current.ScoreboardTick == round.Tick && current.CurrentTickUntil > 0 {
+		until := time.UnixMilli(int64(current.CurrentTickUntil * 1000))
+		sb.TickUntil = &until
+	}
+
+	for idx, service := range round.Services {
+		s := scoreboard.Service{
+			Name:      snap.services.Name(idx),
+			Attackers: service.Attackers,
+			Victims:   service.Victims,
+		}
+		for _, id := range service.FirstBlood {
+			s.FirstBlood = append(s.FirstBlood, teams[id].Name)
+		}
+		sb.Services = append(sb.Services, s)
+	}
+
+	for _, team := range snap.teams {
+		t := scoreboard.Team{
+			Rank:        team.Rank,
+			ID:          strconv.FormatInt(team.ID, 10),
+			Name:        teams[team.ID].Name,
+			Points:      team.Points,
+			PointsDelta: team.OffenseDelta + team.DefenseDelta + team.SLADelta,
+			Offense:     team.Offense,
+			Defense:     team.Defense,
+			SLA:         team.SLA,
+		}
+		for idx, service := range team.Services {
+			t.Services = append(t.Services, scoreboard.TeamService{
+				Service:       snap.services.Name(idx),
+				Status:        scoreboard.StatusName(round.StatusDescriptions, service.Status),
+				StatusCode:    service.Status,
+				Message:       service.Message,
+				Offense:       service.Offense,
+				OffenseDelta:  service.OffenseDelta,
+				Defense:       service.Defense,
+				DefenseDelta:  service.DefenseDelta,
+				SLA:           service.SLA,
+				SLADelta:      service.SLADelta,
+				Captures:      service.Captures,
+				CapturesDelta: service.CapturesDelta,
+				Stolen:        service.Stolen,
+				StolenDelta:   service.StolenDelta,
+			})
+		}
+		sb.Teams = append(sb.Teams, t)
+	}
+
+	return sb, nil
+}
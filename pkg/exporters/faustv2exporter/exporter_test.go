@@ -0,0 +1,245 @@
+package faustv2exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestReplay(t *testing.T) {
+	f := New()
+	if err := f.Configure([]string{"--replay-dir", "../../../sample-data"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := f.Scoreboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// playback starts at the oldest recorded round
+	if sb.Tick != 42 {
+		t.Errorf("tick = %d, want 42", sb.Tick)
+	}
+	if len(sb.Services) == 0 || len(sb.Teams) == 0 {
+		t.Fatalf("scoreboard has %d services and %d teams, want some", len(sb.Services), len(sb.Teams))
+	}
+	named := 0
+	for _, team := range sb.Teams {
+		if team.Name != "" {
+			named++
+		}
+		if len(team.Services) != len(sb.Services) {
+			t.Errorf("team %s has %d services, want %d", team.ID, len(team.Services), len(sb.Services))
+		}
+	}
+	if named == 0 {
+		t.Error("no team got its name from scoreboard_teams.json")
+	}
+}
+
+// rolloverServer is a gameserver during tick rollover: scoreboard_current.json
+// already announces round 5, but its file first answers with first, then is
+// half-written until published.
+type rolloverServer struct {
+	*httptest.Server
+	first int
+
+	mu        sync.Mutex
+	published bool
+	requests  int
+}
+
+func newRolloverServer(t *testing.T, first int) *rolloverServer {
+	s := &rolloverServer{first: first}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/competition/scoreboard-v2/scoreboard_current.json":
+			fmt.Fprint(w, `{"state": 0, "current_tick": 6, "current_tick_until": 0, "scoreboard_tick": 5}`)
+		case "/competition/scoreboard-v2/scoreboard_round_4.json":
+			fmt.Fprint(w, roundJSON(4))
+		case "/competition/scoreboard-v2/scoreboard_round_5.json":
+			s.mu.Lock()
+			s.requests++
+			requests, published := s.requests, s.published
+			s.mu.Unlock()
+
+			switch {
+			case published:
+				fmt.Fprint(w, roundJSON(5))
+			case requests == 1 && s.first == http.StatusNotFound:
+				http.NotFound(w, r)
+			default:
+				fmt.Fprint(w, roundJSON(5)[:20])
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rolloverServer) publish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = true
+}
+
+func (s *rolloverServer) roundRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func roundJSON(tick int) string {
+	return fmt.Sprintf(`{"tick": %d, "scoreboard": [], "status-descriptions": {}, "services": []}`, tick)
+}
+
+// lagObserver records the value of scoreboard_round_lag_ticks.
+type lagObserver struct {
+	metric.Int64Observer
+	values []int64
+}
+
+func (o *lagObserver) Observe(value int64, options ...metric.ObserveOption) {
+	o.values = append(o.values, value)
+}
+
+func roundLag(t *testing.T, f *FaustV2Exporter) int64 {
+	t.Helper()
+	var observer lagObserver
+	if err := f.GetRoundLagMetrics(context.Background(), &observer); err != nil {
+		t.Fatal(err)
+	}
+	if len(observer.values) != 1 {
+		t.Fatalf("round lag observed %d times, want once", len(observer.values))
+	}
+	return observer.values[0]
+}
+
+// waitFor polls cond until it holds, or fails the test.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRoundFallback(t *testing.T) {
+	interval := roundRetryInterval
+	roundRetryInterval = 20 * time.Millisecond
+	t.Cleanup(func() { roundRetryInterval = interval })
+
+	tests := []struct {
+		name  string
+		first int
+	}{
+		{"not yet published", http.StatusNotFound},
+		{"half-written", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRolloverServer(t, tt.first)
+			f := New()
+			if err := f.Configure([]string{"--base-url", server.URL}); err != nil {
+				t.Fatal(err)
+			}
+
+			round, err := f.GetRound(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if round.Tick != 4 {
+				t.Fatalf("round = %d, want the fallback 4", round.Tick)
+			}
+			if lag := roundLag(t, &f); lag != 1 {
+				t.Errorf("lag = %d, want 1", lag)
+			}
+
+			// the background retry gets the half-written file and keeps
+			// serving the fallback
+			waitFor(t, "a retry", func() bool { return server.roundRequests() >= 3 })
+			if round, _ := f.round.Peek(); round.Tick != 4 {
+				t.Errorf("round = %d while 5 is half-written, want 4", round.Tick)
+			}
+			if lag := roundLag(t, &f); lag != 1 {
+				t.Errorf("lag = %d while 5 is half-written, want 1", lag)
+			}
+
+			server.publish()
+			waitFor(t, "round 5", func() bool {
+				round, _ := f.round.Peek()
+				return round.Tick == 5
+			})
+			if lag := roundLag(t, &f); lag != 0 {
+				t.Errorf("lag = %d, want 0", lag)
+			}
+			round, err = f.GetRound(context.Background())
+			if err != nil || round.Tick != 5 {
+				t.Errorf("GetRound = %v, %v, want round 5", round, err)
+			}
+		})
+	}
+}
+
+func TestSnapshotSkipsMismatchedTeams(t *testing.T) {
+	score := `{"c": 0, "dc": [], "o": 1, "do": 0, "d": 1, "dd": 0, "s": 1, "ds": 0, "cap": 0, "dcap": 0, "st": 0, "dst": 0}`
+	team := func(id int, services ...string) string {
+		return fmt.Sprintf(`{"rank": %d, "team_id": %d, "points": 1, "o": 1, "do": 0, "d": 1, "dd": 0, "s": 1, "ds": 0, "services": [%s]}`, id, id, strings.Join(services, ","))
+	}
+	documents := map[string]string{
+		"scoreboard_current.json": `{"state": 0, "current_tick": 2, "current_tick_until": 0, "scoreboard_tick": 1}`,
+		"scoreboard_teams.json":   `{"1": {"name": "alpha"}, "2": {"name": "beta"}, "3": {"name": "gamma"}}`,
+		"scoreboard_round_1.json": `{"tick": 1, "status-descriptions": {"0": "up"}, "services": [` +
+			`{"name": "web", "attackers": 0, "victims": 0, "first_blood": []}, {"name": "db", "attackers": 0, "victims": 0, "first_blood": []}], ` +
+			`"scoreboard": [` + team(1, score, score) + `, ` + team(2, score) + `, ` + team(3, score, score) + `]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if doc, ok := documents[path.Base(r.URL.Path)]; ok {
+			fmt.Fprint(w, doc)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	f := New()
+	if err := f.Configure([]string{"--base-url", server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	sb, err := f.Scoreboard(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, team := range sb.Teams {
+		names = append(names, team.Name)
+	}
+	if want := []string{"alpha", "gamma"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("teams = %q, want %q without the row that has one service", names, want)
+	}
+}
@@ -0,0 +1,549 @@
+package genericexporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/exporters/exporterbase"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/generic"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/services"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/status"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const SCOPE_NAME string = "generic_exporter"
+
+// GenericExporter exports any JSON scoreboard described by a YAML file of
+// URLs and JSONPath expressions. Every configured metric becomes one gauge, per
+// team or per team and service.
+type GenericExporter struct {
+	*exporterbase.Base
+	configPath *string
+
+	config       *generic.Config
+	tickPath     *generic.Path
+	teamsPath    *generic.Path
+	teamIDPath   *generic.Path
+	teamNamePath *generic.Path
+	servicesPath *generic.Path
+	svcNamesPath *generic.Path
+	svcIDPath    *generic.Path
+	rankPath     *generic.Path
+	pointsPath   *generic.Path
+	statusPath   *generic.Path
+	descsPath    *generic.Path
+	valuePaths   []*generic.Path
+
+	gauges []metric.Float64ObservableGauge
+	tick   metric.Int64ObservableGauge
+
+	tickValue  *cache.Value[int64]
+	scoreboard *cache.Value[interface{}]
+	teamNames  *cache.Value[interface{}]
+}
+
+func New() GenericExporter {
+	f := GenericExporter{
+		Base: exporterbase.New("generic", "the gameserver"),
+	}
+
+	f.configPath = f.FS.String("config", "", "YAML file describing the scoreboard URLs and JSONPath mappings, see examples/generic-faustv2.yml")
+
+	return f
+}
+
+// Configure loads and validates the YAML config and compiles its paths. The
+// tick and team_names documents only get a cache when the config has their
+// own URL. No metrics are registered, so that validate and fetch can use it
+// too.
+func (f *GenericExporter) Configure(args []string) error {
+	if err := f.ParseFlags(args); err != nil {
+		return err
+	}
+
+	if *f.configPath == "" {
+		return fmt.Errorf("set --config")
+	}
+
+	cfg, err := generic.LoadConfig(*f.configPath)
+	if err != nil {
+		return err
+	}
+	f.config = cfg
+
+	f.tickPath = mustCompile(cfg.Tick.Path)
+	f.teamsPath = mustCompile(cfg.Scoreboard.Teams)
+	f.teamIDPath = mustCompile(cfg.Scoreboard.TeamID)
+	f.teamNamePath = mustCompile(cfg.Scoreboard.TeamName)
+	f.servicesPath = mustCompile(cfg.Scoreboard.Services)
+	f.svcNamesPath = mustCompile(cfg.Scoreboard.ServiceNames)
+	f.svcIDPath = mustCompile(cfg.Scoreboard.ServiceID)
+	f.rankPath = mustCompile(cfg.Scoreboard.Rank)
+	f.pointsPath = mustCompile(cfg.Scoreboard.Points)
+	f.statusPath = mustCompile(cfg.Scoreboard.ServiceStatus)
+	f.descsPath = mustCompile(cfg.Scoreboard.StatusDescriptions)
+	for _, m := range cfg.Metrics {
+		f.valuePaths = append(f.valuePaths, mustCompile(m.Path))
+	}
+
+	if err := f.NewClient(); err != nil {
+		return err
+	}
+
+	f.scoreboard = exporterbase.NewCache[interface{}](f.Base, "scoreboard")
+	if cfg.Tick.URL != "" {
+		f.tickValue = exporterbase.NewCache[int64](f.Base, "tick")
+	}
+	if cfg.TeamNames != nil {
+		f.teamNames = exporterbase.NewCache[interface{}](f.Base, "team_names")
+	}
+
+	return nil
+}
+
+// Init configures the exporter and registers a gauge per configured metric,
+// plus scoreboard_tick if the config says where the tick is.
+func (f *GenericExporter) Init(args []string) error {
+	if err := f.Configure(args); err != nil {
+		return err
+	}
+
+	meter := otel.Meter(SCOPE_NAME)
+
+	for idx, m := range f.config.Metrics {
+		valuePath := f.valuePaths[idx]
+		var callback metric.Float64Callback
+		if m.Scope == generic.ScopeTeam {
+			callback = f.teamMetricCallback(valuePath)
+		} else {
+			callback = f.serviceMetricCallback(valuePath)
+		}
+
+		gauge, err := meter.Float64ObservableGauge(m.Name, metric.WithDescription(m.Description), metric.WithFloat64Callback(callback))
+
+		if err != nil {
+			return fmt.Errorf("while setting up %s gauge: %w", m.Name, err)
+		}
+
+		f.gauges = append(f.gauges, gauge)
+	}
+
+	if f.tickPath != nil {
+		tick, err := meter.Int64ObservableGauge("scoreboard_tick", metric.WithDescription("Current tick."), metric.WithInt64Callback(f.GetTickMetrics))
+
+		if err != nil {
+			return fmt.Errorf("while setting up tick gauge: %w", err)
+		}
+
+		f.tick = tick
+	}
+
+	return f.RegisterDataAge(meter)
+}
+
+// mustCompile compiles a path that Config.Validate has already checked.
+// Empty paths are returned as nil.
+func mustCompile(expr string) *generic.Path {
+	if expr == "" {
+		return nil
+	}
+	p, err := generic.CompilePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (f *GenericExporter) GetTick(ctx context.Context) (int64, error) {
+	if f.config.Tick.URL == "" {
+		data, err := f.GetScoreboard(ctx)
+		if err != nil {
+			return -1, err
+		}
+		return f.tickFrom(data)
+	}
+
+	return f.tickValue.Get(ctx, func(ctx context.Context) (int64, error) {
+		data, err := generic.LoadDocument(ctx, f.Client, f.config.Tick.URL)
+		if err != nil {
+			return -1, err
+		}
+		return f.tickFrom(data)
+	})
+}
+
+func (f *GenericExporter) tickFrom(doc interface{}) (int64, error) {
+	value, err := generic.ToFloat(f.tickPath.First(doc))
+	if err != nil {
+		return -1, fmt.Errorf("while reading tick at %s: %w", f.tickPath, err)
+	}
+	return int64(value), nil
+}
+
+func (f *GenericExporter) GetScoreboard(ctx context.Context) (interface{}, error) {
+	return f.scoreboard.Get(ctx, func(ctx context.Context) (interface{}, error) {
+		url := f.config.Scoreboard.URL
+		if strings.Contains(url, "{tick}") {
+			tick, err := f.GetTick(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("while getting tick: %w", err)
+			}
+			url = strings.ReplaceAll(url, "{tick}", strconv.FormatInt(tick, 10))
+		}
+		return generic.LoadDocument(ctx, f.Client, url)
+	})
+}
+
+func (f *GenericExporter) GetTeamNames(ctx context.Context) (interface{}, error) {
+	return f.teamNames.Get(ctx, func(ctx context.Context) (interface{}, error) {
+		return generic.LoadDocument(ctx, f.Client, f.config.TeamNames.URL)
+	})
+}
+
+// teamName resolves the label for a team entry: the inline name if
+// configured, then the team_names document, then the team ID itself.
+func (f *GenericExporter) teamName(team interface{}, teamNames interface{}) string {
+	if f.teamNamePath != nil {
+		if name := generic.ToString(f.teamNamePath.First(team)); name != "" {
+			return name
+		}
+	}
+
+	id := generic.ToString(f.teamIDPath.First(team))
+	if teamNames != nil {
+		expr := strings.ReplaceAll(f.config.TeamNames.Path, "{id}", id)
+		if p, err := generic.CompilePath(expr); err == nil {
+			if name := generic.ToString(p.First(teamNames)); name != "" {
+				return name
+			}
+		}
+	}
+	return id
+}
+
+// serviceIndex builds the service list of a scoreboard document. Without
+// service_names, services are named by their position.
+func (f *GenericExporter) serviceIndex(data interface{}) *services.Index {
+	var names []string
+	if f.svcNamesPath != nil && f.svcIDPath == nil {
+		for _, name := range f.svcNamesPath.Eval(data) {
+			names = append(names, generic.ToString(name))
+		}
+	}
+	return services.New("scoreboard", names)
+}
+
+// serviceNames labels the per-service entries of a team row, by their own ID
+// if service_id is configured and by position otherwise. Rows that cannot be
+// labelled are skipped.
+func (f *GenericExporter) serviceNames(index *services.Index, svc []interface{}) ([]string, bool) {
+	names := make([]string, len(svc))
+
+	if f.svcIDPath != nil {
+		for idx, service := range svc {
+			names[idx] = generic.ToString(f.svcIDPath.First(service))
+			if names[idx] == "" {
+				index.Skip(services.ReasonMissingID)
+				return nil, false
+			}
+		}
+		return names, true
+	}
+
+	if !index.Fits(len(svc)) {
+		return nil, false
+	}
+	for idx := range svc {
+		names[idx] = index.Name(idx)
+	}
+	return names, true
+}
+
+// loadTeams fetches everything a callback needs to label team entries.
+func (f *GenericExporter) loadTeams(ctx context.Context) (interface{}, []interface{}, interface{}, error) {
+	data, err := f.GetScoreboard(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("while loading scoreboard: %w", err)
+	}
+
+	var teamNames interface{}
+	if f.config.TeamNames != nil {
+		teamNames, err = f.GetTeamNames(ctx)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("while loading team names: %w", err)
+		}
+	}
+
+	return data, f.teamsPath.Eval(data), teamNames, nil
+}
+
+func (f *GenericExporter) serviceMetricCallback(valuePath *generic.Path) metric.Float64Callback {
+	return func(ctx context.Context, observer metric.Float64Observer) error {
+		ctx, cancel := f.ScrapeContext(ctx)
+		defer cancel()
+
+		data, teams, teamNames, err := f.loadTeams(ctx)
+		if err != nil {
+			return err
+		}
+
+		index := f.serviceIndex(data)
+		defer index.Report(ctx)
+
+		for _, team := range teams {
+			teamName := f.teamName(team, teamNames)
+			svc := f.servicesPath.Eval(team)
+			svcNames, ok := f.serviceNames(index, svc)
+			if !ok {
+				continue
+			}
+			for idx, service := range svc {
+				value, err := generic.ToFloat(valuePath.First(service))
+				if err != nil {
+					continue
+				}
+				observer.Observe(
+					value,
+					metric.WithAttributes(
+						attribute.String("team", teamName),
+						attribute.String("service", svcNames[idx]),
+					),
+				)
+			}
+		}
+		return nil
+	}
+}
+
+func (f *GenericExporter) teamMetricCallback(valuePath *generic.Path) metric.Float64Callback {
+	return func(ctx context.Context, observer metric.Float64Observer) error {
+		ctx, cancel := f.ScrapeContext(ctx)
+		defer cancel()
+
+		_, teams, teamNames, err := f.loadTeams(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, team := range teams {
+			value, err := generic.ToFloat(valuePath.First(team))
+			if err != nil {
+				continue
+			}
+			observer.Observe(
+				value,
+				metric.WithAttributes(
+					attribute.String("team", f.teamName(team, teamNames)),
+				),
+			)
+		}
+		return nil
+	}
+}
+
+func (f *GenericExporter) GetTickMetrics(ctx context.Context, observer metric.Int64Observer) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	tick, err := f.GetTick(ctx)
+	if err != nil {
+		return fmt.Errorf("while getting tick: %w", err)
+	}
+
+	observer.Observe(tick)
+	return nil
+}
+
+// Refresh loads the scoreboard and, if configured, the team names into their
+// caches.
+func (f *GenericExporter) Refresh(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	_, _, _, err := f.loadTeams(ctx)
+	return err
+}
+
+// CheckSchema loads every configured document: the scoreboard, the team
+// names and the tick. They are decoded without a fixed schema, so there is no
+// drift to find, but one that cannot be fetched or parsed shows at startup.
+func (f *GenericExporter) CheckSchema(ctx context.Context) error {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	return exporterbase.CheckSchema(ctx,
+		func(ctx context.Context) error {
+			_, _, _, err := f.loadTeams(ctx)
+			return err
+		},
+		func(ctx context.Context) error {
+			if f.tickPath == nil {
+				return nil
+			}
+			_, err := f.GetTick(ctx)
+			return err
+		},
+	)
+}
+
+// Status reports only the URLs the config uses. The tick comes from the tick
+// document if there is one, and from the scoreboard otherwise.
+func (f *GenericExporter) Status() status.Report {
+	tick := int64(-1)
+	var urls []status.URL
+
+	if f.config.Tick.URL != "" {
+		urls = append(urls, status.URL{Name: "tick", URL: f.config.Tick.URL})
+		if value, ok := f.tickValue.Peek(); ok {
+			tick = value
+		}
+	} else if f.tickPath != nil {
+		if data, ok := f.scoreboard.Peek(); ok {
+			if value, err := f.tickFrom(data); err == nil {
+				tick = value
+			}
+		}
+	}
+
+	urls = append(urls, status.URL{Name: "scoreboard", URL: f.config.Scoreboard.URL})
+
+	if f.config.TeamNames != nil {
+		urls = append(urls, status.URL{Name: "team_names", URL: f.config.TeamNames.URL})
+	}
+
+	return f.Report(f.scoreboard, tick, urls...)
+}
+
+// Scoreboard returns the scoreboard in the backend-neutral format. Metric
+// values end up in Values, by metric name.
+func (f *GenericExporter) Scoreboard(ctx context.Context) (*scoreboard.Scoreboard, error) {
+	ctx, cancel := f.ScrapeContext(ctx)
+	defer cancel()
+
+	data, teams, teamNames, err := f.loadTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := &scoreboard.Scoreboard{
+		Source:    "generic",
+		Tick:      -1,
+		FetchedAt: time.Now(),
+	}
+	if f.tickPath != nil {
+		if tick, err := f.GetTick(ctx); err == nil {
+			sb.Tick = tick
+		}
+	}
+
+	index := f.serviceIndex(data)
+	defer index.Report(ctx)
+
+	descriptions := f.statusDescriptions(data)
+	seen := make(map[string]bool)
+	for idx := 0; idx < index.Len(); idx++ {
+		seen[index.Name(idx)] = true
+		sb.Services = append(sb.Services, scoreboard.Service{Name: index.Name(idx)})
+	}
+
+	for position, team := range teams {
+		t := scoreboard.Team{
+			Rank:   int64(position + 1),
+			ID:     generic.ToString(f.teamIDPath.First(team)),
+			Name:   f.teamName(team, teamNames),
+			Values: f.values(generic.ScopeTeam, team),
+		}
+		if f.rankPath != nil {
+			if rank, err := generic.ToFloat(f.rankPath.First(team)); err == nil {
+				t.Rank = int64(rank)
+			}
+		}
+		if f.pointsPath != nil {
+			if points, err := generic.ToFloat(f.pointsPath.First(team)); err == nil {
+				t.Points = points
+			}
+		}
+
+		if f.servicesPath != nil {
+			svc := f.servicesPath.Eval(team)
+			svcNames, ok := f.serviceNames(index, svc)
+			if !ok {
+				continue
+			}
+			for idx, service := range svc {
+				if !seen[svcNames[idx]] {
+					seen[svcNames[idx]] = true
+					sb.Services = append(sb.Services, scoreboard.Service{Name: svcNames[idx]})
+				}
+				t.Services = append(t.Services, f.teamService(svcNames[idx], service, descriptions))
+			}
+		}
+
+		sb.Teams = append(sb.Teams, t)
+	}
+
+	sort.SliceStable(sb.Teams, func(i, j int) bool {
+		return sb.Teams[i].Rank < sb.Teams[j].Rank
+	})
+	return sb, nil
+}
+
+// statusDescriptions reads the status descriptions of a scoreboard document.
+func (f *GenericExporter) statusDescriptions(data interface{}) map[int64]string {
+	if f.descsPath == nil {
+		return nil
+	}
+	object, ok := f.descsPath.First(data).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	descriptions := make(map[int64]string, len(object))
+	for code, name := range object {
+		if c, err := strconv.ParseInt(code, 10, 64); err == nil {
+			descriptions[c] = generic.ToString(name)
+		}
+	}
+	return descriptions
+}
+
+func (f *GenericExporter) teamService(name string, service interface{}, descriptions map[int64]string) scoreboard.TeamService {
+	ts := scoreboard.TeamService{
+		Service: name,
+		Values:  f.values(generic.ScopeService, service),
+	}
+	if f.statusPath != nil {
+		value := f.statusPath.First(service)
+		if code, err := generic.ToFloat(value); err == nil {
+			ts.StatusCode = int64(code)
+			ts.Status = scoreboard.StatusName(descriptions, ts.StatusCode)
+		} else {
+			ts.Status = generic.ToString(value)
+		}
+	}
+	return ts
+}
+
+// values evaluates the metrics of a scope on a team or per-service entry.
+func (f *GenericExporter) values(scope string, entry interface{}) map[string]float64 {
+	values := make(map[string]float64)
+	for idx, m := range f.config.Metrics {
+		if m.Scope != scope {
+			continue
+		}
+		if value, err := generic.ToFloat(f.valuePaths[idx].First(entry)); err == nil {
+			values[m.Name] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
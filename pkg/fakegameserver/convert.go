@@ -0,0 +1,81 @@
+package fakegameserver
+
+import (
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv1"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv2"
+)
+
+// toScoreboardJson renders a v2 round in the shape of the old
+// /competition/scoreboard.json.
+func toScoreboardJson(round *faustv2.ScoreboardRoundJson, teams faustv2.ScoreboardTeamsJson) *faustv1.ScoreboardJson {
+	out := &faustv1.ScoreboardJson{
+		Tick:               round.Tick,
+		Teams:              []faustv1.ScoreboardJsonTeam{},
+		StatusDescriptions: round.StatusDescriptions,
+	}
+	for _, team := range round.Scoreboard {
+		v1Team := faustv1.ScoreboardJsonTeam{
+			Rank:    team.Rank,
+			ID:      team.ID,
+			Name:    teams[team.ID].Name,
+			Offense: team.Offense,
+			Defense: team.Defense,
+			SLA:     team.SLA,
+			Total:   team.Points,
+		}
+		for _, svc := range team.Services {
+			v1Team.Services = append(v1Team.Services, &faustv1.Service{
+				Status:  svc.Status,
+				Offense: svc.Offense,
+				Defense: svc.Defense,
+				SLA:     svc.SLA,
+			})
+		}
+		out.Teams = append(out.Teams, v1Team)
+	}
+	return out
+}
+
+// toStatusJson renders the last few v2 rounds in the shape of the old
+// /competition/status.json. rounds must be ordered oldest first.
+func toStatusJson(rounds []*faustv2.ScoreboardRoundJson, teams faustv2.ScoreboardTeamsJson) *faustv1.StatusJson {
+	latest := rounds[len(rounds)-1]
+	out := &faustv1.StatusJson{
+		Ticks:              []int64{},
+		Teams:              []faustv1.StatusJsonTeam{},
+		StatusDescriptions: latest.StatusDescriptions,
+		Services:           []string{},
+	}
+	for _, svc := range latest.Services {
+		out.Services = append(out.Services, svc.Name)
+	}
+	for _, round := range rounds {
+		out.Ticks = append(out.Ticks, round.Tick)
+	}
+
+	for _, team := range latest.Scoreboard {
+		statusTeam := faustv1.StatusJsonTeam{
+			ID:   team.ID,
+			Name: teams[team.ID].Name,
+		}
+		for _, round := range rounds {
+			statuses := make([]interface{}, len(out.Services))
+			for idx := range statuses {
+				statuses[idx] = int64(-1)
+			}
+			for _, roundTeam := range round.Scoreboard {
+				if roundTeam.ID != team.ID {
+					continue
+				}
+				for idx, svc := range roundTeam.Services {
+					if idx < len(statuses) {
+						statuses[idx] = svc.Status
+					}
+				}
+			}
+			statusTeam.Ticks = append(statusTeam.Ticks, statuses)
+		}
+		out.Teams = append(out.Teams, statusTeam)
+	}
+	return out
+}
@@ -0,0 +1,231 @@
+package fakegameserver
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/envflag"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv2"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/replay"
+)
+
+// how many ticks of history status.json shows
+const statusTicks = 5
+
+// FakeGameserver serves the Faust v1 and v2 scoreboard endpoints of a made up
+// (or replayed) game, for testing the exporter without a real gameserver.
+type FakeGameserver struct {
+	fs           *flag.FlagSet
+	dataDir      *string
+	numTeams     *int
+	numServices  *int
+	tickDuration *time.Duration
+	seed         *int64
+
+	mu        sync.Mutex
+	game      *syntheticGame
+	replay    *replay.Replay
+	tickUntil time.Time
+}
+
+func New() *FakeGameserver {
+	f := &FakeGameserver{
+		fs: flag.NewFlagSet("fake-gameserver", flag.ContinueOnError),
+	}
+
+	f.dataDir = f.fs.String("data-dir", "", "serve recorded scoreboard_round_N.json files from this directory (e.g. sample-data) instead of a synthesized game")
+	f.numTeams = f.fs.Int("teams", 10, "number of teams in the synthesized game")
+	f.numServices = f.fs.Int("services", 5, "number of services in the synthesized game")
+	f.tickDuration = f.fs.Duration("fake-tick-duration", 10*time.Second, "how often the game advances by one tick")
+	f.seed = f.fs.Int64("seed", time.Now().UnixNano(), "random seed for the synthesized game")
+
+	return f
+}
+
+// Init starts the game and serves it below /competition/ on the default
+// mux.
+func (f *FakeGameserver) Init(args []string) error {
+	if err := f.start(args); err != nil {
+		return err
+	}
+	http.Handle("/competition/", f)
+	return nil
+}
+
+// start parses the flags and loads the recorded game or starts a synthesized
+// one.
+func (f *FakeGameserver) start(args []string) error {
+	if err := envflag.Parse(f.fs, args); err != nil {
+		return err
+	}
+
+	if *f.tickDuration <= 0 {
+		return fmt.Errorf("--fake-tick-duration must be positive")
+	}
+
+	if *f.dataDir != "" {
+		r, err := replay.New(*f.dataDir, *f.tickDuration, 1)
+		if err != nil {
+			return fmt.Errorf("while loading --data-dir: %w", err)
+		}
+		f.replay = r
+	} else {
+		if *f.numTeams < 1 || *f.numServices < 1 {
+			return fmt.Errorf("--teams and --services must be at least 1")
+		}
+		f.game = newSyntheticGame(*f.numTeams, *f.numServices, *f.seed)
+		f.tickUntil = time.Now().Add(*f.tickDuration)
+		go f.run()
+		logging.Info(context.Background(), "fake gameserver started", "teams", *f.numTeams, "services", *f.numServices, "tick_duration", *f.tickDuration)
+	}
+	return nil
+}
+
+// run advances the synthesized game on a timer.
+func (f *FakeGameserver) run() {
+	ticker := time.NewTicker(*f.tickDuration)
+	defer ticker.Stop()
+	for range ticker.C {
+		f.mu.Lock()
+		tick := f.game.advance()
+		f.tickUntil = time.Now().Add(*f.tickDuration)
+		f.mu.Unlock()
+		logging.Debug(context.Background(), "fake gameserver tick", "tick", tick)
+	}
+}
+
+func (f *FakeGameserver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var doc interface{}
+	var err error
+
+	switch req.URL.Path {
+	case "/competition/scoreboard.json":
+		doc, err = f.scoreboardJson()
+	case "/competition/status.json":
+		doc, err = f.statusJson()
+	default:
+		if path.Dir(req.URL.Path) != "/competition/scoreboard-v2" {
+			http.NotFound(w, req)
+			return
+		}
+		doc, err = f.v2Document(path.Base(req.URL.Path))
+	}
+
+	if os.IsNotExist(err) {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if raw, ok := doc.([]byte); ok {
+		w.Write(raw)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		logging.Error(req.Context(), "fake gameserver: while writing response", "path", req.URL.Path, "error", err)
+	}
+}
+
+// v2Document returns a scoreboard-v2 document either as raw JSON or as a
+// value to be encoded.
+func (f *FakeGameserver) v2Document(name string) (interface{}, error) {
+	if f.replay != nil {
+		return f.replay.Document(name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch name {
+	case "scoreboard_current.json":
+		return &faustv2.CurrentJson{
+			State:            0,
+			CurrentTick:      f.game.tick + 1,
+			CurrentTickUntil: float64(f.tickUntil.Unix()),
+			ScoreboardTick:   f.game.tick,
+		}, nil
+	case "scoreboard_teams.json":
+		return f.game.teams, nil
+	}
+
+	var tick int64
+	if _, err := fmt.Sscanf(name, "scoreboard_round_%d.json", &tick); err != nil {
+		return nil, os.ErrNotExist
+	}
+	round, ok := f.game.rounds[tick]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return round, nil
+}
+
+// latestRounds returns up to n rounds ending at the current scoreboard tick,
+// oldest first, plus the team names.
+func (f *FakeGameserver) latestRounds(n int) ([]*faustv2.ScoreboardRoundJson, faustv2.ScoreboardTeamsJson, error) {
+	if f.replay == nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var rounds []*faustv2.ScoreboardRoundJson
+		for tick := f.game.tick - int64(n) + 1; tick <= f.game.tick; tick++ {
+			if round, ok := f.game.rounds[tick]; ok {
+				rounds = append(rounds, round)
+			}
+		}
+		return rounds, f.game.teams, nil
+	}
+
+	var teams faustv2.ScoreboardTeamsJson
+	if data, err := f.replay.Document("scoreboard_teams.json"); err == nil {
+		if err := json.Unmarshal(data, &teams); err != nil {
+			return nil, nil, fmt.Errorf("while parsing scoreboard_teams.json: %w", err)
+		}
+	}
+
+	var rounds []*faustv2.ScoreboardRoundJson
+	for tick := f.replay.CurrentTick() - int64(n) + 1; tick <= f.replay.CurrentTick(); tick++ {
+		data, err := f.replay.Document(fmt.Sprintf("scoreboard_round_%d.json", tick))
+		if err != nil {
+			continue
+		}
+		round := new(faustv2.ScoreboardRoundJson)
+		if err := json.Unmarshal(data, round); err != nil {
+			return nil, nil, fmt.Errorf("while parsing round %d: %w", tick, err)
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, teams, nil
+}
+
+func (f *FakeGameserver) scoreboardJson() (interface{}, error) {
+	rounds, teams, err := f.latestRounds(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rounds) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return toScoreboardJson(rounds[0], teams), nil
+}
+
+func (f *FakeGameserver) statusJson() (interface{}, error) {
+	rounds, teams, err := f.latestRounds(statusTicks)
+	if err != nil {
+		return nil, err
+	}
+	if len(rounds) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return toStatusJson(rounds, teams), nil
+}
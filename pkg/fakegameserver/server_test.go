@@ -0,0 +1,154 @@
+package fakegameserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv1"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv2"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// serve starts a fake gameserver with the given flags. Documents with schema
+// drift fail to load while the test runs.
+func serve(t *testing.T, args ...string) (string, *http.Client) {
+	t.Helper()
+	f := New()
+	if err := f.start(args); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	httpclient.SetStrictSchema(true)
+	t.Cleanup(func() { httpclient.SetStrictSchema(false) })
+
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server.URL + "/competition", client
+}
+
+func TestSynthetic(t *testing.T) {
+	base, client := serve(t, "--teams", "4", "--services", "3", "--fake-tick-duration", "20ms", "--seed", "1")
+	ctx := context.Background()
+
+	// let a few ticks pass
+	var current *faustv2.CurrentJson
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		current, err = faustv2.LoadCurrentJson(ctx, client, base+"/scoreboard-v2/scoreboard_current.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.ScoreboardTick >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still at tick %d", current.ScoreboardTick)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if current.CurrentTick != current.ScoreboardTick+1 {
+		t.Errorf("current = %+v, want the current tick right after the scoreboard tick", current)
+	}
+
+	teams, err := faustv2.LoadTeamsJson(ctx, client, base+"/scoreboard-v2/scoreboard_teams.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(teams) != 4 {
+		t.Errorf("%d teams, want 4", len(teams))
+	}
+
+	round, err := faustv2.LoadScoreboardRoundJson(ctx, client, base+"/scoreboard-v2/scoreboard_round_%d.json", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if round.Tick != 2 || len(round.Scoreboard) != 4 || len(round.Services) != 3 {
+		t.Errorf("round has tick %d, %d teams and %d services, want 2, 4 and 3", round.Tick, len(round.Scoreboard), len(round.Services))
+	}
+	for _, team := range round.Scoreboard {
+		if len(team.Services) != 3 {
+			t.Errorf("team %d has %d services, want 3", team.ID, len(team.Services))
+		}
+	}
+
+	_, err = faustv2.LoadScoreboardRoundJson(ctx, client, base+"/scoreboard-v2/scoreboard_round_%d.json", 1000)
+	if !errors.Is(err, httpclient.ErrNotYetPublished) {
+		t.Errorf("round from the future = %v, want %v", err, httpclient.ErrNotYetPublished)
+	}
+
+	scoreboard, err := faustv1.LoadScoreboardJson(ctx, client, base+"/scoreboard.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scoreboard.Teams) != 4 || scoreboard.Teams[0].Name == "" || len(scoreboard.Teams[0].Services) != 3 {
+		t.Errorf("scoreboard.json = %+v, want 4 named teams with 3 services", scoreboard)
+	}
+
+	status, err := faustv1.LoadStatusJson(ctx, client, base+"/status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Services) != 3 || len(status.Teams) != 4 || len(status.Ticks) == 0 {
+		t.Errorf("status.json has %d services, %d teams and %d ticks, want 3, 4 and some", len(status.Services), len(status.Teams), len(status.Ticks))
+	}
+	for _, team := range status.Teams {
+		if len(team.Ticks) != len(status.Ticks) {
+			t.Errorf("team %d has %d ticks of status, want %d", team.ID, len(team.Ticks), len(status.Ticks))
+		}
+	}
+}
+
+// The Faust v1 documents are converted from the recorded rounds.
+func TestDataDir(t *testing.T) {
+	base, client := serve(t, "--data-dir", "../../sample-data", "--fake-tick-duration", "1h")
+	ctx := context.Background()
+
+	round, err := faustv2.LoadScoreboardRoundJson(ctx, client, base+"/scoreboard-v2/scoreboard_round_%d.json", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scoreboard, err := faustv1.LoadScoreboardJson(ctx, client, base+"/scoreboard.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoreboard.Tick != round.Tick || len(scoreboard.Teams) != len(round.Scoreboard) {
+		t.Errorf("scoreboard.json has tick %d and %d teams, want %d and %d", scoreboard.Tick, len(scoreboard.Teams), round.Tick, len(round.Scoreboard))
+	}
+
+	status, err := faustv1.LoadStatusJson(ctx, client, base+"/status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Services) != len(round.Services) {
+		t.Errorf("status.json has %d services, want %d", len(status.Services), len(round.Services))
+	}
+
+	for _, path := range []string{"/unknown.json", "/scoreboard-v2/nested/scoreboard_current.json"} {
+		resp, err := httpclient.Get(ctx, client, base+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package fakegameserver
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/fetchers/faustv2"
+)
+
+// Faust status codes, as listed in status-descriptions
+var statusDescriptions = map[int64]string{
+	-1: "not checked",
+	0:  "up",
+	1:  "down",
+	2:  "faulty",
+	3:  "flag not found",
+	4:  "recovering",
+}
+
+// how many rounds of history are served, older rounds return 404
+const keepRounds = 100
+
+// syntheticGame makes up a plausible faustv2 game: services go up and down,
+// teams steal flags from each other and the ranking shifts over time.
+type syntheticGame struct {
+	rng      *rand.Rand
+	teams    faustv2.ScoreboardTeamsJson
+	teamIDs  []int64
+	services []string
+	// how good each team is at attacking, 0..1
+	skill  map[int64]float64
+	rounds map[int64]*faustv2.ScoreboardRoundJson
+	tick   int64
+}
+
+func newSyntheticGame(numTeams int, numServices int, seed int64) *syntheticGame {
+	g := &syntheticGame{
+		rng:    rand.New(rand.NewSource(seed)),
+		teams:  make(faustv2.ScoreboardTeamsJson),
+		skill:  make(map[int64]float64),
+		rounds: make(map[int64]*faustv2.ScoreboardRoundJson),
+	}
+
+	for i := 0; i < numTeams; i++ {
+		id := int64(i + 1)
+		g.teamIDs = append(g.teamIDs, id)
+		g.teams[id] = faustv2.TeamsJsonTeam{
+			Name:        fmt.Sprintf("Team %02d", id),
+			Affiliation: "Fake University",
+			Logo:        fmt.Sprintf("/uploads/team-images/thumbnails/%d.png", id),
+		}
+		g.skill[id] = g.rng.Float64()
+	}
+
+	for i := 0; i < numServices; i++ {
+		g.services = append(g.services, fmt.Sprintf("service-%d", i+1))
+	}
+
+	g.rounds[0] = g.initialRound()
+	return g
+}
+
+func (g *syntheticGame) initialRound() *faustv2.ScoreboardRoundJson {
+	round := &faustv2.ScoreboardRoundJson{
+		StatusDescriptions: statusDescriptions,
+	}
+	for _, name := range g.services {
+		round.Services = append(round.Services, faustv2.ScoreboardV2Service{Name: name, FirstBlood: []int64{}})
+	}
+	for idx, id := range g.teamIDs {
+		team := faustv2.ScoreboardV2Team{Rank: int64(idx + 1), ID: id}
+		for range g.services {
+			team.Services = append(team.Services, &faustv2.ScoreboardV2ServiceScore{
+				Status:      -1,
+				StatusDelta: []int64{-1, -1, -1},
+			})
+		}
+		round.Scoreboard = append(round.Scoreboard, team)
+	}
+	return round
+}
+
+func (g *syntheticGame) randomStatus() int64 {
+	roll := g.rng.Float64()
+	switch {
+	case roll < 0.85:
+		return 0
+	case roll < 0.90:
+		return 1
+	case roll < 0.95:
+		return 2
+	case roll < 0.98:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// advance plays one tick and returns the new scoreboard tick.
+func (g *syntheticGame) advance() int64 {
+	prev := g.rounds[g.tick]
+	g.tick++
+	round := &faustv2.ScoreboardRoundJson{
+		Tick:               g.tick,
+		StatusDescriptions: statusDescriptions,
+	}
+
+	byID := make(map[int64]*faustv2.ScoreboardV2Team)
+	for _, prevTeam := range prev.Scoreboard {
+		team := faustv2.ScoreboardV2Team{ID: prevTeam.ID}
+		for _, prevSvc := range prevTeam.Services {
+			svc := *prevSvc
+			svc.Status = g.randomStatus()
+			svc.StatusDelta = append([]int64{svc.Status}, prevSvc.StatusDelta[:2]...)
+			svc.Message = ""
+			if svc.Status != 0 {
+				svc.Message = statusDescriptions[svc.Status]
+			}
+			svc.OffenseDelta, svc.DefenseDelta, svc.SLADelta = 0, 0, 0
+			svc.CapturesDelta, svc.StolenDelta = 0, 0
+			if svc.Status == 0 {
+				svc.SLADelta = 14
+			} else if svc.Status == 4 {
+				svc.SLADelta = 7
+			}
+			team.Services = append(team.Services, &svc)
+		}
+		round.Scoreboard = append(round.Scoreboard, team)
+	}
+	for idx := range round.Scoreboard {
+		byID[round.Scoreboard[idx].ID] = &round.Scoreboard[idx]
+	}
+
+	for svcIdx, name := range g.services {
+		service := faustv2.ScoreboardV2Service{Name: name, FirstBlood: prev.Services[svcIdx].FirstBlood}
+		attackers := make(map[int64]bool)
+		victims := make(map[int64]bool)
+
+		for _, attackerID := range g.teamIDs {
+			attacker := byID[attackerID].Services[svcIdx]
+			for _, victimID := range g.teamIDs {
+				if victimID == attackerID || g.rng.Float64() > g.skill[attackerID]*0.3 {
+					continue
+				}
+				victim := byID[victimID].Services[svcIdx]
+				if victim.Status != 0 {
+					continue
+				}
+				attacker.CapturesDelta++
+				attacker.OffenseDelta += 1 + g.rng.Float64()
+				victim.StolenDelta++
+				victim.DefenseDelta -= 0.5
+				attackers[attackerID] = true
+				victims[victimID] = true
+				if len(service.FirstBlood) == 0 {
+					service.FirstBlood = []int64{attackerID}
+				}
+			}
+		}
+
+		service.Attackers = prev.Services[svcIdx].Attackers
+		service.Victims = prev.Services[svcIdx].Victims
+		if int64(len(attackers)) > service.Attackers {
+			service.Attackers = int64(len(attackers))
+		}
+		if int64(len(victims)) > service.Victims {
+			service.Victims = int64(len(victims))
+		}
+		round.Services = append(round.Services, service)
+	}
+
+	for idx := range round.Scoreboard {
+		team := &round.Scoreboard[idx]
+		team.Offense, team.OffenseDelta = 0, 0
+		team.Defense, team.DefenseDelta = 0, 0
+		team.SLA, team.SLADelta = 0, 0
+		for _, svc := range team.Services {
+			svc.Offense += svc.OffenseDelta
+			svc.Defense += svc.DefenseDelta
+			svc.SLA += svc.SLADelta
+			svc.Captures += svc.CapturesDelta
+			svc.Stolen += svc.StolenDelta
+			team.Offense += svc.Offense
+			team.OffenseDelta += svc.OffenseDelta
+			team.Defense += svc.Defense
+			team.DefenseDelta += svc.DefenseDelta
+			team.SLA += svc.SLA
+			team.SLADelta += svc.SLADelta
+		}
+		team.Points = team.Offense + team.Defense + team.SLA
+	}
+
+	sort.SliceStable(round.Scoreboard, func(i, j int) bool {
+		return round.Scoreboard[i].Points > round.Scoreboard[j].Points
+	})
+	for idx := range round.Scoreboard {
+		round.Scoreboard[idx].Rank = int64(idx + 1)
+	}
+
+	g.rounds[g.tick] = round
+	delete(g.rounds, g.tick-keepRounds)
+	return g.tick
+}
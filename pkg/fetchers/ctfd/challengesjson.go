@@ -0,0 +1,46 @@
+package ctfd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func LoadChallengesJson(ctx context.Context, client *http.Client, url string, token string) ([]Challenge, error) {
+	var unpacked []Challenge
+	if err := loadJson(ctx, client, url, token, &unpacked); err != nil {
+		return nil, err
+	}
+	return unpacked, nil
+}
+
+func LoadSolvesJson(ctx context.Context, client *http.Client, urlPattern string, token string, challengeId int64) ([]Solve, error) {
+	url := fmt.Sprintf(urlPattern, challengeId)
+	var unpacked []Solve
+	if err := loadJson(ctx, client, url, token, &unpacked); err != nil {
+		return nil, err
+	}
+	return unpacked, nil
+}
+
+// Challenge is a row of /api/v1/challenges.
+type Challenge struct {
+	ID       int64   `json:"id"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	Value    float64 `json:"value"`
+	Solves   int64   `json:"solves"`
+	Category string  `json:"category"`
+}
+
+func (Challenge) IgnoredFields() []string {
+	return []string{"solved_by_me", "tags", "template", "script"}
+}
+
+// Solve is a row of /api/v1/challenges/<id>/solves, oldest first.
+type Solve struct {
+	AccountID  int64  `json:"account_id"`
+	Name       string `json:"name"`
+	Date       string `json:"date"`
+	AccountURL string `json:"account_url"`
+}
@@ -0,0 +1,42 @@
+package ctfd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+)
+
+// loadJson performs an API request with an optional access token and unpacks
+// the response envelope into out.
+func loadJson(ctx context.Context, client *http.Client, url string, token string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("while creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	envelope := apiResponse{Data: out}
+	if err := httpclient.DoJSON(client, req, &envelope); err != nil {
+		return err
+	}
+	if !envelope.Success {
+		return fmt.Errorf("CTFd API returned success=false for %s", url)
+	}
+
+	return nil
+}
+
+// apiResponse is the envelope around every CTFd API response.
+type apiResponse struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data"`
+}
+
+func (apiResponse) IgnoredFields() []string {
+	return []string{"meta", "errors"}
+}
@@ -0,0 +1,29 @@
+package ctfd
+
+import (
+	"context"
+	"net/http"
+)
+
+func LoadScoreboardJson(ctx context.Context, client *http.Client, url string, token string) ([]ScoreboardEntry, error) {
+	var unpacked []ScoreboardEntry
+	if err := loadJson(ctx, client, url, token, &unpacked); err != nil {
+		return nil, err
+	}
+	return unpacked, nil
+}
+
+// ScoreboardEntry is a row of /api/v1/scoreboard. In team mode an account is
+// a team, in user mode it is a user.
+type ScoreboardEntry struct {
+	Position    int64   `json:"pos"`
+	AccountID   int64   `json:"account_id"`
+	AccountURL  string  `json:"account_url"`
+	AccountType string  `json:"account_type"`
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+}
+
+func (ScoreboardEntry) IgnoredFields() []string {
+	return []string{"oauth_id", "bracket_id", "bracket_name", "members"}
+}
@@ -1,27 +1,15 @@
 package faustv1
 
 import (
-	"encoding/json"
-	"io"
-	"log"
+	"context"
+	"net/http"
 
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
 )
 
-func LoadScoreboardJson(url string) (*ScoreboardJson, error) {
-	resp, err := httpclient.HttpClient.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	log.Printf("GET %s => %s", url, resp.Status)
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+func LoadScoreboardJson(ctx context.Context, client *http.Client, url string) (*ScoreboardJson, error) {
 	unpacked := new(ScoreboardJson)
-	err = json.Unmarshal(data, unpacked)
-	if err != nil {
+	if err := httpclient.GetJSON(ctx, client, url, unpacked); err != nil {
 		return nil, err
 	}
 
@@ -1,29 +1,17 @@
 package faustv1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"net/http"
 
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
 )
 
-func LoadStatusJson(url string) (*StatusJson, error) {
-	resp, err := httpclient.HttpClient.Get(url)
-	log.Printf("GET %s => %s", url, resp.Status)
-	if err != nil {
-		return nil, fmt.Errorf("while loading status.json: %w", err)
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("while reading body: %w", err)
-	}
+func LoadStatusJson(ctx context.Context, client *http.Client, url string) (*StatusJson, error) {
 	unpacked := new(StatusJson)
-	err = json.Unmarshal(data, unpacked)
-	if err != nil {
-		return nil, fmt.Errorf("while unmarshaling into StatusJson: %w", err)
+	if err := httpclient.GetJSON(ctx, client, url, unpacked); err != nil {
+		return nil, fmt.Errorf("while loading status.json: %w", err)
 	}
 
 	return unpacked, nil
@@ -1,27 +1,15 @@
 package faustv2
 
 import (
-	"encoding/json"
-	"io"
-	"log"
+	"context"
+	"net/http"
 
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
 )
 
-func LoadCurrentJson(url string) (*CurrentJson, error) {
-	resp, err := httpclient.HttpClient.Get(url)
-	log.Printf("GET %s => %s", url, resp.Status)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+func LoadCurrentJson(ctx context.Context, client *http.Client, url string) (*CurrentJson, error) {
 	unpacked := new(CurrentJson)
-	err = json.Unmarshal(data, unpacked)
-	if err != nil {
+	if err := httpclient.GetJSON(ctx, client, url, unpacked); err != nil {
 		return nil, err
 	}
 
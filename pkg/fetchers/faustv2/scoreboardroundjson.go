@@ -1,30 +1,21 @@
 package faustv2
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
+	"net/http"
 
 	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
 )
 
-func LoadScoreboardRoundJson(urlPattern string, roundId int64) (*ScoreboardRoundJson, error) {
+// LoadScoreboardRoundJson fetches the scoreboard of a round. A missing round
+// file is reported as httpclient.ErrNotYetPublished, since rounds are only
+// requested once current.json announces them.
+func LoadScoreboardRoundJson(ctx context.Context, client *http.Client, urlPattern string, roundId int64) (*ScoreboardRoundJson, error) {
 	url := fmt.Sprintf(urlPattern, roundId)
-	resp, err := httpclient.HttpClient.Get(url)
-	log.Printf("GET %s => %s", url, resp.Status)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 	unpacked := new(ScoreboardRoundJson)
-	err = json.Unmarshal(data, unpacked)
-	if err != nil {
-		return nil, err
+	if err := httpclient.GetJSON(ctx, client, url, unpacked); err != nil {
+		return nil, httpclient.NotYetPublished(err)
 	}
 
 	return unpacked, nil
@@ -51,7 +42,8 @@ type ScoreboardV2Team struct {
 }
 
 type ScoreboardV2ServiceScore struct {
-	Message       string  `json:"m"`
+	// checker message, only sent when there is one
+	Message       string  `json:"m,omitempty" schema:"optional"`
 	Status        int64   `json:"c"`
 	StatusDelta   []int64 `json:"dc"`
 	Offense       float64 `json:"o"`
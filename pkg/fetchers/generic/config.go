@@ -0,0 +1,185 @@
+package generic
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes where a scoreboard lives and how to pull metrics out of
+// it. See examples/generic-faustv2.yml for an annotated example.
+type Config struct {
+	Tick       TickConfig       `yaml:"tick"`
+	Scoreboard ScoreboardConfig `yaml:"scoreboard"`
+	TeamNames  *TeamNamesConfig `yaml:"team_names"`
+	Metrics    []MetricConfig   `yaml:"metrics"`
+}
+
+type TickConfig struct {
+	// URL of the document holding the current tick. When empty, the path is
+	// evaluated on the scoreboard document instead.
+	URL string `yaml:"url"`
+	// Path to the tick inside that document
+	Path string `yaml:"path"`
+}
+
+type ScoreboardConfig struct {
+	// URL of the scoreboard document. {tick} is replaced with the current tick.
+	URL string `yaml:"url"`
+	// Path to every team entry, relative to the document root
+	Teams string `yaml:"teams"`
+	// Path to the team ID, relative to a team entry
+	TeamID string `yaml:"team_id"`
+	// Path to the team name, relative to a team entry. Falls back to
+	// team_names, and then to the team ID.
+	TeamName string `yaml:"team_name"`
+	// Path to every per-service entry, relative to a team entry
+	Services string `yaml:"services"`
+	// Path to every service name, relative to the document root. Mapped to
+	// per-service entries by position.
+	ServiceNames string `yaml:"service_names"`
+	// Path to the service name or ID, relative to a per-service entry. Takes
+	// precedence over service_names, as it does not depend on the order.
+	ServiceID string `yaml:"service_id"`
+	// Path to the team's rank, relative to a team entry. Without it, teams
+	// are ranked in document order.
+	Rank string `yaml:"rank"`
+	// Path to the team's total points, relative to a team entry
+	Points string `yaml:"points"`
+	// Path to the service status, relative to a per-service entry
+	ServiceStatus string `yaml:"service_status"`
+	// Path to an object of status descriptions by status code, relative to
+	// the document root, for service_status values that are numbers
+	StatusDescriptions string `yaml:"status_descriptions"`
+}
+
+type TeamNamesConfig struct {
+	// URL of the document holding team names
+	URL string `yaml:"url"`
+	// Path to the team name. {id} is replaced with the team ID.
+	Path string `yaml:"path"`
+}
+
+type MetricConfig struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Path to the value, relative to a per-service entry (or a team entry
+	// when scope is "team")
+	Path string `yaml:"path"`
+	// "service" (default) or "team"
+	Scope string `yaml:"scope"`
+}
+
+const (
+	ScopeService = "service"
+	ScopeTeam    = "team"
+)
+
+// metricName is what Prometheus accepts as a metric name.
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// reservedNames are exported by the exporter itself next to the configured
+// metrics, as are all names starting with scoreboard_exporter_.
+var reservedNames = map[string]bool{
+	"scoreboard_tick":             true,
+	"scoreboard_data_age_seconds": true,
+	"scoreboard_events_total":     true,
+	"target_info":                 true,
+	"otel_scope_info":             true,
+}
+
+// LoadConfig reads and validates a YAML config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading config: %w", err)
+	}
+
+	cfg := new(Config)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("while parsing config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that required fields are present, metric names are valid
+// and unique, and every path compiles.
+func (c *Config) Validate() error {
+	if c.Scoreboard.URL == "" {
+		return fmt.Errorf("scoreboard.url is required")
+	}
+	if c.Scoreboard.Teams == "" {
+		return fmt.Errorf("scoreboard.teams is required")
+	}
+	if c.Scoreboard.TeamID == "" {
+		return fmt.Errorf("scoreboard.team_id is required")
+	}
+	if c.Tick.URL != "" && c.Tick.Path == "" {
+		return fmt.Errorf("tick.path is required when tick.url is set")
+	}
+	if strings.Contains(c.Scoreboard.URL, "{tick}") && c.Tick.URL == "" {
+		return fmt.Errorf("scoreboard.url uses {tick}, so tick.url is required")
+	}
+	if c.TeamNames != nil && (c.TeamNames.URL == "" || c.TeamNames.Path == "") {
+		return fmt.Errorf("team_names needs both url and path")
+	}
+	if len(c.Metrics) == 0 {
+		return fmt.Errorf("at least one metric is required")
+	}
+
+	paths := []string{c.Tick.Path, c.Scoreboard.Teams, c.Scoreboard.TeamID, c.Scoreboard.TeamName, c.Scoreboard.Services, c.Scoreboard.ServiceNames, c.Scoreboard.ServiceID, c.Scoreboard.Rank, c.Scoreboard.Points, c.Scoreboard.ServiceStatus, c.Scoreboard.StatusDescriptions}
+	names := make(map[string]int, len(c.Metrics))
+	for idx := range c.Metrics {
+		m := &c.Metrics[idx]
+		if m.Name == "" || m.Path == "" {
+			return fmt.Errorf("metrics[%d] needs both name and path", idx)
+		}
+		if !metricName.MatchString(m.Name) {
+			return fmt.Errorf("metrics[%d] name %q is not a valid metric name, use letters, digits, _ and :", idx, m.Name)
+		}
+		if reservedNames[m.Name] || strings.HasPrefix(m.Name, "scoreboard_exporter_") {
+			return fmt.Errorf("metrics[%d] name %q is reserved for the exporter's own metrics", idx, m.Name)
+		}
+		if other, ok := names[m.Name]; ok {
+			return fmt.Errorf("metrics[%d] has the same name as metrics[%d]: %q", idx, other, m.Name)
+		}
+		names[m.Name] = idx
+		if m.Scope == "" {
+			m.Scope = ScopeService
+		}
+		if m.Scope != ScopeService && m.Scope != ScopeTeam {
+			return fmt.Errorf("metrics[%d] has unknown scope %q", idx, m.Scope)
+		}
+		if m.Scope == ScopeService && c.Scoreboard.Services == "" {
+			return fmt.Errorf("metrics[%d] is per service, but scoreboard.services is not set", idx)
+		}
+		paths = append(paths, m.Path)
+	}
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, err := CompilePath(p); err != nil {
+			return err
+		}
+	}
+
+	// the team ID is only filled in at scrape time, so check the path with
+	// a stand-in
+	if c.TeamNames != nil {
+		if _, err := CompilePath(strings.ReplaceAll(c.TeamNames.Path, "{id}", "0")); err != nil {
+			return fmt.Errorf("team_names.path: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package generic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTeamNamesPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"$['{id}'].name", false},
+		{"$.teams[{id}].name", false},
+		{"$.{id}.name", false},
+		{"$['{id}'.name", true},
+		{"teams.{id}", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			cfg := Config{
+				Scoreboard: ScoreboardConfig{URL: "http://localhost/scoreboard.json", Teams: "$.teams[*]", TeamID: "$.id"},
+				TeamNames:  &TeamNamesConfig{URL: "http://localhost/teams.json", Path: tt.path},
+				Metrics:    []MetricConfig{{Name: "scoreboard_points", Path: "$.points", Scope: ScopeTeam}},
+			}
+			err := cfg.Validate()
+			if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "team_names.path")) {
+				t.Errorf("Validate = %v, want a team_names.path error", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate = %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateMetricNames(t *testing.T) {
+	metric := func(name string) MetricConfig {
+		return MetricConfig{Name: name, Path: "$.points", Scope: ScopeTeam}
+	}
+	tests := []struct {
+		name    string
+		metrics []MetricConfig
+		wantErr string
+	}{
+		{"valid", []MetricConfig{metric("scoreboard_points"), metric("ctf:team_score"), metric("_hidden")}, ""},
+		{"leading digit", []MetricConfig{metric("2nd_place")}, "not a valid metric name"},
+		{"dash", []MetricConfig{metric("scoreboard-points")}, "not a valid metric name"},
+		{"dot", []MetricConfig{metric("scoreboard.points")}, "not a valid metric name"},
+		{"space", []MetricConfig{metric("scoreboard points")}, "not a valid metric name"},
+		{"tick", []MetricConfig{metric("scoreboard_tick")}, "reserved"},
+		{"data age", []MetricConfig{metric("scoreboard_data_age_seconds")}, "reserved"},
+		{"events", []MetricConfig{metric("scoreboard_events_total")}, "reserved"},
+		{"exporter prefix", []MetricConfig{metric("scoreboard_exporter_fetch_errors_total")}, "reserved"},
+		{"duplicate", []MetricConfig{metric("scoreboard_points"), metric("scoreboard_rank"), metric("scoreboard_points")}, "metrics[2] has the same name as metrics[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Scoreboard: ScoreboardConfig{URL: "http://localhost/scoreboard.json", Teams: "$.teams[*]", TeamID: "$.id"},
+				Metrics:    tt.metrics,
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	if _, err := LoadConfig("../../../examples/generic-faustv2.yml"); err != nil {
+		t.Errorf("LoadConfig = %v", err)
+	}
+}
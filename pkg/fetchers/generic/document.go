@@ -0,0 +1,18 @@
+package generic
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+)
+
+// LoadDocument fetches any JSON document without assuming its shape.
+func LoadDocument(ctx context.Context, client *http.Client, url string) (interface{}, error) {
+	var unpacked interface{}
+	if err := httpclient.GetJSON(ctx, client, url, &unpacked); err != nil {
+		return nil, err
+	}
+
+	return unpacked, nil
+}
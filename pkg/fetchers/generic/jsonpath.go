@@ -0,0 +1,166 @@
+package generic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath expression. Only the subset needed to walk
+// scoreboard documents is supported:
+//
+//	$                 the document root
+//	.field ['field']  object member
+//	[3]               array element
+//	[*] .*            every array element or object member
+type Path struct {
+	expr  string
+	steps []step
+}
+
+type step struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// CompilePath parses a JSONPath expression.
+func CompilePath(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	rest := strings.TrimSpace(expr)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("path %q must start with $", expr)
+	}
+	rest = rest[1:]
+
+	for len(rest) > 0 {
+		switch {
+		case strings.HasPrefix(rest, ".*"):
+			p.steps = append(p.steps, step{wildcard: true})
+			rest = rest[2:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("path %q has an empty field name", expr)
+			}
+			p.steps = append(p.steps, step{field: name})
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("path %q has an unterminated [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if inner == "*" {
+				p.steps = append(p.steps, step{wildcard: true})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p.steps = append(p.steps, step{field: inner[1 : len(inner)-1]})
+			} else if idx, err := strconv.Atoi(inner); err == nil {
+				p.steps = append(p.steps, step{index: idx, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("path %q has an invalid subscript [%s]", expr, inner)
+			}
+		default:
+			return nil, fmt.Errorf("path %q has unexpected %q", expr, rest)
+		}
+	}
+
+	return p, nil
+}
+
+// Eval returns every value the path selects in doc. Object wildcards are
+// expanded in key order so that results are stable between fetches.
+func (p *Path) Eval(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, node := range current {
+			switch v := node.(type) {
+			case map[string]interface{}:
+				if s.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if !s.isIndex {
+					if child, ok := v[s.field]; ok {
+						next = append(next, child)
+					}
+				}
+			case []interface{}:
+				if s.wildcard {
+					next = append(next, v...)
+				} else if s.isIndex {
+					idx := s.index
+					if idx < 0 {
+						idx += len(v)
+					}
+					if idx >= 0 && idx < len(v) {
+						next = append(next, v[idx])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// First returns the first value the path selects in doc, or nil.
+func (p *Path) First(doc interface{}) interface{} {
+	values := p.Eval(doc)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// ToFloat converts a JSON scalar into a number.
+func ToFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	case nil:
+		return 0, fmt.Errorf("value is missing")
+	default:
+		return 0, fmt.Errorf("value of type %T is not a number", v)
+	}
+}
+
+// ToString converts a JSON scalar into a label value.
+func ToString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", s)
+	}
+}
@@ -0,0 +1,99 @@
+package generic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const pathTestDocument = `{
+	"tick": 42,
+	"teams": [
+		{"id": 1, "name": "alpha", "services": [{"sla": 1}, {"sla": 0.5}]},
+		{"id": 2, "name": "beta", "services": [{"sla": 0}]}
+	],
+	"names": {"2": {"name": "beta"}, "1": {"name": "alpha"}},
+	"odd key": {"with.dot": true}
+}`
+
+func TestPathEval(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(pathTestDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{"$.tick", []interface{}{42.0}},
+		{"$['tick']", []interface{}{42.0}},
+		{`$["odd key"]['with.dot']`, []interface{}{true}},
+		{"$.teams[0].name", []interface{}{"alpha"}},
+		{"$.teams[-1].name", []interface{}{"beta"}},
+		{"$.teams[*].id", []interface{}{1.0, 2.0}},
+		{"$.teams.*.id", []interface{}{1.0, 2.0}},
+		{"$.teams[*].services[*].sla", []interface{}{1.0, 0.5, 0.0}},
+		// object members come out in key order, not document order
+		{"$.names.*.name", []interface{}{"alpha", "beta"}},
+		{"$.names['2'].name", []interface{}{"beta"}},
+		// missing keys and mismatched steps select nothing
+		{"$.round", nil},
+		{"$.teams[2].name", nil},
+		{"$.teams[-3].name", nil},
+		{"$.teams[*].flags", nil},
+		{"$.tick.value", nil},
+		{"$.teams.name", nil},
+		{"$.names[0]", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := CompilePath(tt.expr)
+			if err != nil {
+				t.Fatalf("CompilePath: %v", err)
+			}
+			if got := p.Eval(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathRoot(t *testing.T) {
+	p, err := CompilePath(" $ ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{"tick": 1.0}
+	if got := p.First(doc); !reflect.DeepEqual(got, doc) {
+		t.Errorf("First = %#v, want the document", got)
+	}
+}
+
+func TestPathFirstMissing(t *testing.T) {
+	p, err := CompilePath("$.teams[0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.First(map[string]interface{}{}); got != nil {
+		t.Errorf("First = %#v, want nil", got)
+	}
+}
+
+func TestCompilePathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"tick",
+		"$.",
+		"$..tick",
+		"$.teams[0",
+		"$.teams[first]",
+		"$.teams['0]",
+		"$teams",
+	} {
+		if _, err := CompilePath(expr); err == nil {
+			t.Errorf("CompilePath(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+// headerList collects repeated --header "Name: value" flags.
+type headerList []string
+
+func (h *headerList) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerList) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header %q must look like \"Name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// ReadSecret resolves a credential flag or setting. "env:NAME" reads an
+// environment variable, "file:/path" reads a file, anything else is used as
+// is.
+func ReadSecret(spec string) (string, error) {
+	switch {
+	case strings.HasPrefix(spec, "env:"):
+		name := strings.TrimPrefix(spec, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(spec, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(spec, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return spec, nil
+	}
+}
+
+// authTransport adds credentials to every request and, if a login form is
+// configured, keeps a logged in session alive.
+type authTransport struct {
+	next          http.RoundTripper
+	headers       http.Header
+	bearerToken   string
+	basicUser     string
+	basicPassword string
+
+	login *sessionLogin
+}
+
+func (c *Config) authTransport(next http.RoundTripper) (*authTransport, error) {
+	t := &authTransport{
+		next:    next,
+		headers: make(http.Header),
+	}
+
+	for _, header := range c.Headers {
+		name, value, _ := strings.Cut(header, ":")
+		t.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	var err error
+	if c.BearerToken != "" {
+		if t.bearerToken, err = ReadSecret(c.BearerToken); err != nil {
+			return nil, fmt.Errorf("while reading bearer token: %w", err)
+		}
+	}
+	if c.BasicAuthUser != "" {
+		t.basicUser = c.BasicAuthUser
+		if t.basicPassword, err = ReadSecret(c.BasicAuthPassword); err != nil {
+			return nil, fmt.Errorf("while reading basic auth password: %w", err)
+		}
+	}
+	if c.LoginURL != "" {
+		password, err := ReadSecret(c.LoginPassword)
+		if err != nil {
+			return nil, fmt.Errorf("while reading login password: %w", err)
+		}
+		t.login, err = newSessionLogin(next, c.LoginURL, c.LoginUser, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.login != nil {
+		if err := t.login.ensure(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.next.RoundTrip(t.authorize(req))
+	if err != nil || t.login == nil || !t.login.expired(resp) {
+		return resp, err
+	}
+
+	logging.Info(req.Context(), "session expired, logging in again", "host", req.URL.Host)
+	resp.Body.Close()
+	t.login.invalidate()
+	if err := t.login.ensure(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(t.authorize(req))
+}
+
+// authorize returns a copy of req with credentials and session cookies added.
+func (t *authTransport) authorize(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	if t.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	}
+	if t.basicUser != "" {
+		req.SetBasicAuth(t.basicUser, t.basicPassword)
+	}
+	if t.login != nil {
+		req.Header.Del("Cookie")
+		for _, cookie := range t.login.jar.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
+	}
+	return req
+}
+
+var csrfTokenRegexp = regexp.MustCompile(`name=["']csrfmiddlewaretoken["']\s+value=["']([^"']+)["']`)
+
+// sessionLogin logs into a Django login form, as used by ctf-gameserver,
+// and keeps the session cookie.
+type sessionLogin struct {
+	client   *http.Client
+	jar      http.CookieJar
+	loginURL *url.URL
+	user     string
+	password string
+
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+func newSessionLogin(transport http.RoundTripper, loginURL string, user string, password string) (*sessionLogin, error) {
+	parsed, err := url.Parse(loginURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid login URL: %w", err)
+	}
+	if user == "" {
+		return nil, fmt.Errorf("a login URL needs --login-user")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionLogin{
+		client: &http.Client{
+			Transport: transport,
+			Jar:       jar,
+			// a successful login redirects away, which is all we need to see
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		jar:      jar,
+		loginURL: parsed,
+		user:     user,
+		password: password,
+	}, nil
+}
+
+func (s *sessionLogin) invalidate() {
+	s.mu.Lock()
+	s.loggedIn = false
+	s.mu.Unlock()
+}
+
+// expired reports whether a response means the session is no longer valid:
+// Django redirects to the login form, or the server refuses outright.
+func (s *sessionLogin) expired(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return false
+	}
+	location, err := resp.Location()
+	return err == nil && location.Path == s.loginURL.Path
+}
+
+// ensure logs in unless there is a session already.
+func (s *sessionLogin) ensure(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loggedIn {
+		return nil
+	}
+
+	resp, err := Get(ctx, s.client, s.loginURL.String())
+	if err != nil {
+		return fmt.Errorf("while loading login form: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("while reading login form: %w", err)
+	}
+	match := csrfTokenRegexp.FindSubmatch(body)
+	if match == nil {
+		return fmt.Errorf("no csrfmiddlewaretoken in login form at %s", s.loginURL)
+	}
+
+	form := url.Values{
+		"username":            {s.user},
+		"password":            {s.password},
+		"csrfmiddlewaretoken": {string(match[1])},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.loginURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Django's CSRF check requires a same-origin Referer over HTTPS
+	req.Header.Set("Referer", s.loginURL.String())
+
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("while logging in: %w", err)
+	}
+	resp.Body.Close()
+
+	// a failed login renders the form again with 200 OK
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return fmt.Errorf("login as %s at %s failed: %s", s.user, s.loginURL, resp.Status)
+	}
+
+	logging.Info(ctx, "logged in", "user", s.user, "host", s.loginURL.Host)
+	s.loggedIn = true
+	return nil
+}
@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const SCOPE_NAME string = "httpclient"
+
+var (
+	certExpiryMu   sync.Mutex
+	certExpiry     = make(map[string]time.Time)
+	certExpiryOnce sync.Once
+)
+
+// certExpiryTransport remembers when the leaf certificate of each TLS
+// endpoint expires.
+type certExpiryTransport struct {
+	next http.RoundTripper
+}
+
+func (t *certExpiryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		certExpiryMu.Lock()
+		certExpiry[req.URL.Host] = resp.TLS.PeerCertificates[0].NotAfter
+		certExpiryMu.Unlock()
+	}
+	return resp, err
+}
+
+func registerCertExpiryGauge() {
+	meter := otel.Meter(SCOPE_NAME)
+	_, err := meter.Float64ObservableGauge("scoreboard_tls_cert_expiry_timestamp_seconds", metric.WithDescription("Unix time when the scoreboard endpoint's TLS certificate expires. Faceted by host."), metric.WithFloat64Callback(observeCertExpiry))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up certificate expiry gauge", "error", err)
+	}
+}
+
+func observeCertExpiry(ctx context.Context, observer metric.Float64Observer) error {
+	certExpiryMu.Lock()
+	defer certExpiryMu.Unlock()
+
+	for host, notAfter := range certExpiry {
+		observer.Observe(
+			float64(notAfter.Unix()),
+			metric.WithAttributes(
+				attribute.String("host", host),
+			),
+		)
+	}
+	return nil
+}
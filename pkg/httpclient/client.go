@@ -1,18 +1,216 @@
 package httpclient
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
 )
 
-var HttpClient http.Client
+// Config is the per-scoreboard HTTP client configuration.
+type Config struct {
+	// name of the backend using the client, added to log lines
+	Source string
+
+	// skip certificate verification entirely
+	Insecure bool
+	// PEM file with extra CA certificates to trust
+	CAFile string
+	// PEM files with a client certificate and its key, for mutual TLS
+	CertFile string
+	KeyFile  string
+	// overrides the SNI and verified host name
+	ServerName string
+
+	// extra "Name: value" headers sent with every request
+	Headers headerList
+	// credentials may be given as is, as env:NAME or as file:/path
+	BearerToken       string
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// http://, https://, socks5:// or socks5h:// proxy to send requests
+	// through. Falls back to the HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables.
+	Proxy string
+
+	// deadline for a single request, and for everything one scrape fetches
+	RequestTimeout time.Duration
+	ScrapeTimeout  time.Duration
+
+	// failed requests are retried with exponential backoff starting at
+	// RetryBackoff. After BreakerFailures failed requests in a row, an
+	// endpoint is left alone for BreakerCooldown.
+	Retries         int
+	RetryBackoff    time.Duration
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// ctf-gameserver (Django) login form to get a session cookie from
+	LoginURL      string
+	LoginUser     string
+	LoginPassword string
+}
+
+// RegisterFlags adds flags for every Config field to a subcommand's FlagSet.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Insecure, "tls-insecure", false, "do not verify the scoreboard's TLS certificate")
+	fs.StringVar(&c.CAFile, "tls-ca-file", "", "PEM file with CA certificates to trust in addition to the system ones")
+	fs.StringVar(&c.CertFile, "tls-cert-file", "", "PEM client certificate for mutual TLS, requires --tls-key-file")
+	fs.StringVar(&c.KeyFile, "tls-key-file", "", "PEM client certificate key for mutual TLS")
+	fs.StringVar(&c.ServerName, "tls-server-name", "", "server name to send as SNI and verify the certificate against, if different from the URL host")
+	fs.StringVar(&c.Proxy, "proxy", "", "proxy URL, e.g. http://jumphost:3128 or socks5h://localhost:1080 for ssh -D (overrides HTTP_PROXY/HTTPS_PROXY)")
+	fs.DurationVar(&c.RequestTimeout, "request-timeout", 10*time.Second, "give up on a single scoreboard request after this long")
+	fs.DurationVar(&c.ScrapeTimeout, "scrape-timeout", 20*time.Second, "give up on all scoreboard requests needed to refresh one metric after this long")
+	fs.IntVar(&c.Retries, "retries", 2, "how often to retry a failed scoreboard request")
+	fs.DurationVar(&c.RetryBackoff, "retry-backoff", 500*time.Millisecond, "delay before the first retry, doubled for every further retry")
+	fs.IntVar(&c.BreakerFailures, "breaker-failures", 5, "stop requesting an endpoint after this many failed requests in a row, 0 to disable")
+	fs.DurationVar(&c.BreakerCooldown, "breaker-cooldown", 30*time.Second, "how long to stop requesting an endpoint once it failed too often")
+	fs.Var(&c.Headers, "header", "extra request header as \"Name: value\", can be repeated")
+	fs.StringVar(&c.BearerToken, "bearer-token", "", "send Authorization: Bearer with this token (or env:NAME, file:/path)")
+	fs.StringVar(&c.BasicAuthUser, "basic-auth-user", "", "HTTP basic auth user name")
+	fs.StringVar(&c.BasicAuthPassword, "basic-auth-password", "", "HTTP basic auth password (or env:NAME, file:/path)")
+	fs.StringVar(&c.LoginURL, "login-url", "", "ctf-gameserver login form to log in to before fetching, e.g. https://2023.faustctf.net/login/")
+	fs.StringVar(&c.LoginUser, "login-user", "", "user name for --login-url")
+	fs.StringVar(&c.LoginPassword, "login-password", "", "password for --login-url (or env:NAME, file:/path)")
+}
+
+var protocols = make(map[string]http.RoundTripper)
+
+var middlewares []func(http.RoundTripper) http.RoundTripper
+
+// RegisterProtocol makes clients created afterwards send requests for URLs
+// with the given scheme to rt instead of the network.
+func RegisterProtocol(scheme string, rt http.RoundTripper) {
+	protocols[scheme] = rt
+}
+
+// Use wraps the transport of every client created afterwards, e.g. to record
+// responses.
+func Use(middleware func(http.RoundTripper) http.RoundTripper) {
+	middlewares = append(middlewares, middleware)
+}
+
+// New creates an HTTP client for one scoreboard.
+func New(cfg Config) (*http.Client, error) {
+	certExpiryOnce.Do(registerCertExpiryGauge)
+	driftOnce.Do(registerSchemaDriftGauge)
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
 
-func init() {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: true,
+	transport.TLSClientConfig = tlsConfig
+	if cfg.Proxy != "" {
+		proxyURL, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	// file:///abs/path/scoreboard.json reads straight from disk
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	for scheme, rt := range protocols {
+		transport.RegisterProtocol(scheme, rt)
+	}
+
+	var rt http.RoundTripper = &certExpiryTransport{next: transport}
+	rt, err = cfg.authTransport(rt)
+	if err != nil {
+		return nil, err
+	}
+	rt = cfg.retryTransport(rt)
+	for _, middleware := range middlewares {
+		rt = middleware(rt)
+	}
+
+	// RequestTimeout is applied per attempt by the retry transport
+	return &http.Client{
+		Transport: rt,
+	}, nil
+}
+
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+		ServerName:         c.ServerName,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("while reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("a client certificate needs both --tls-cert-file and --tls-key-file")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("while loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseProxy validates a proxy URL. Both socks5:// and socks5h:// send host
+// names to the proxy to resolve, so names that only exist on the far side of
+// an SSH tunnel or VPN jump host work either way.
+func parseProxy(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		// older Go versions only know the socks5 spelling, which already
+		// resolves remotely
+		proxyURL.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return proxyURL, nil
+}
+
+// Get sends a GET request that is cancelled together with ctx.
+func Get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// ScrapeContext bounds everything fetched for one metrics scrape.
+func (c *Config) ScrapeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Source != "" {
+		ctx = logging.With(ctx, "source", c.Source)
 	}
-	HttpClient = http.Client{
-		Transport: transport,
+	if c.ScrapeTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, c.ScrapeTimeout)
 }
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Kinds of fetch failures. Check for them with errors.Is.
+var (
+	// the request never got an HTTP answer: connection, TLS, timeout or an
+	// open circuit breaker
+	ErrNetwork = errors.New("network error")
+	// 404, the document does not exist
+	ErrNotFound = errors.New("not found")
+	// 404 for a document that has been announced but not written yet, like
+	// the round file of a tick that just ended
+	ErrNotYetPublished = errors.New("not yet published")
+	// 5xx, the gameserver or a proxy in front of it is broken
+	ErrServerError = errors.New("server error")
+	// any other non-2xx status
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// the body is not the JSON document we expected, e.g. an HTML error page
+	ErrDecode = errors.New("decode error")
+	// the document has unknown or missing fields and --strict is set
+	ErrSchemaDrift = errors.New("schema drift")
+)
+
+// FetchError describes a failed fetch of one document.
+type FetchError struct {
+	// one of the Err* kinds above
+	Kind error
+	URL  string
+	// HTTP status code, or 0 if there was no response
+	StatusCode int
+	// label for the fetch errors metric, more specific than Kind
+	Reason string
+	Err    error
+}
+
+func (e *FetchError) Error() string {
+	if e.StatusCode != 0 && e.Err == nil {
+		return fmt.Sprintf("GET %s: %v (HTTP %d)", e.URL, e.Kind, e.StatusCode)
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("GET %s: %v (HTTP %d): %v", e.URL, e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("GET %s: %v: %v", e.URL, e.Kind, e.Err)
+}
+
+func (e *FetchError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// NotYetPublished turns a not found error into a not yet published one, for
+// callers that know the document was announced. Other errors are returned
+// unchanged.
+func NotYetPublished(err error) error {
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.Kind != ErrNotFound {
+		return err
+	}
+	published := *fetchErr
+	published.Kind = ErrNotYetPublished
+	published.Reason = "not_yet_published"
+	return &published
+}
+
+func networkError(url string, err error) *FetchError {
+	reason := "network"
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		reason = "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		reason = "timeout"
+	case errors.Is(err, context.Canceled):
+		reason = "canceled"
+	}
+	return &FetchError{Kind: ErrNetwork, URL: url, Reason: reason, Err: err}
+}
+
+func statusError(url string, statusCode int) *FetchError {
+	switch {
+	case statusCode == 404:
+		return &FetchError{Kind: ErrNotFound, URL: url, StatusCode: statusCode, Reason: "not_found"}
+	case statusCode >= 500:
+		return &FetchError{Kind: ErrServerError, URL: url, StatusCode: statusCode, Reason: "server_error"}
+	default:
+		return &FetchError{Kind: ErrUnexpectedStatus, URL: url, StatusCode: statusCode, Reason: fmt.Sprintf("status_%d", statusCode)}
+	}
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+)
+
+// GetJSON fetches url and unmarshals the response body into out.
+func GetJSON(ctx context.Context, client *http.Client, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return DoJSON(client, req, out)
+}
+
+// DoJSON sends req and unmarshals the response body into out. Failures are
+// returned as *FetchError. Every call is counted in the exporter's own fetch
+// metrics.
+func DoJSON(client *http.Client, req *http.Request, out interface{}) error {
+	start := time.Now()
+	size, status, err := doJSON(client, req, out)
+	duration := time.Since(start)
+	endpoint := Endpoint(req.URL)
+
+	reason := ""
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		reason = fetchErr.Reason
+	}
+	metrics.ObserveFetch(req.Context(), endpoint, duration, size, reason, err)
+
+	if err != nil {
+		logging.Warn(req.Context(), "fetch failed", "endpoint", endpoint, "status", status, "duration", duration, "reason", reason, "error", err)
+	} else {
+		logging.Debug(req.Context(), "fetched", "endpoint", endpoint, "url", req.URL.String(), "status", status, "duration", duration, "bytes", size)
+	}
+	return err
+}
+
+func doJSON(client *http.Client, req *http.Request, out interface{}) (int, int, error) {
+	url := req.URL.String()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, networkError(url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		io.Copy(io.Discard, resp.Body)
+		return 0, resp.StatusCode, statusError(url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fetchErr := networkError(url, err)
+		fetchErr.StatusCode = resp.StatusCode
+		return 0, resp.StatusCode, fetchErr
+	}
+
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		return len(data), resp.StatusCode, &FetchError{
+			Kind:       ErrDecode,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Reason:     "decode",
+			Err:        fmt.Errorf("%w (Content-Type %q)", err, resp.Header.Get("Content-Type")),
+		}
+	}
+
+	if err := checkSchema(req.Context(), req.URL, resp.StatusCode, data, out); err != nil {
+		return len(data), resp.StatusCode, err
+	}
+
+	return len(data), resp.StatusCode, nil
+}
+
+var numberRegexp = regexp.MustCompile(`([_/-])\d+`)
+
+// Endpoint identifies a URL in metrics and circuit breakers. Tick numbers and
+// IDs are left out, so that all scoreboard_round_N.json files are one
+// endpoint and the number of series stays bounded.
+func Endpoint(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + numberRegexp.ReplaceAllString(u.Path, "${1}N")
+}
@@ -0,0 +1,227 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+// ErrCircuitOpen is returned without sending a request while an endpoint's
+// circuit breaker is open.
+var ErrCircuitOpen = fmt.Errorf("circuit breaker open")
+
+// retryTransport retries failed requests with exponential backoff and jitter,
+// and stops sending requests to an endpoint for a while after too many
+// consecutive failures. Once the cooldown is over, a single trial request
+// decides whether the endpoint is used again or left alone for another
+// cooldown.
+type retryTransport struct {
+	next            http.RoundTripper
+	retries         int
+	backoff         time.Duration
+	maxBackoff      time.Duration
+	attemptTimeout  time.Duration
+	breakerFailures int
+	breakerCooldown time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	// the cooldown is over and a trial request is in flight
+	breakerHalfOpen
+)
+
+type breaker struct {
+	state     breakerState
+	failures  int
+	openUntil time.Time
+}
+
+func (c *Config) retryTransport(next http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		next:            next,
+		retries:         c.Retries,
+		backoff:         c.RetryBackoff,
+		maxBackoff:      10 * c.RetryBackoff,
+		attemptTimeout:  c.RequestTimeout,
+		breakerFailures: c.BreakerFailures,
+		breakerCooldown: c.BreakerCooldown,
+		breakers:        make(map[string]*breaker),
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := Endpoint(req.URL)
+	if err := t.allow(key); err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = t.attempt(req)
+		if !retryable(resp, err) {
+			break
+		}
+		if attempt >= t.retries {
+			break
+		}
+
+		delay := t.delay(attempt)
+		if err != nil {
+			logging.Debug(req.Context(), "fetch failed, retrying", "endpoint", key, "url", req.URL.String(), "delay", delay, "error", err)
+		} else {
+			logging.Debug(req.Context(), "fetch failed, retrying", "endpoint", key, "url", req.URL.String(), "delay", delay, "status", resp.StatusCode)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			t.abandon(key)
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
+	t.record(req.Context(), key, !retryable(resp, err))
+	return resp, err
+}
+
+// attempt sends one try with its own deadline. The deadline stays in effect
+// until the response body is closed.
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.attemptTimeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.attemptTimeout)
+	resp, err := t.next.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// delay is the exponential backoff for an attempt, with up to 50% jitter.
+func (t *retryTransport) delay(attempt int) time.Duration {
+	delay := t.backoff << attempt
+	if delay > t.maxBackoff || delay <= 0 {
+		delay = t.maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryable reports whether a request failed in a way that might go away:
+// network errors, overload and server errors. 4xx answers are final.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func (t *retryTransport) allow(key string) error {
+	if t.breakerFailures <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.breakers[key]
+	if b == nil {
+		return nil
+	}
+	switch b.state {
+	case breakerOpen:
+		if time.Now().Before(b.openUntil) {
+			return fmt.Errorf("%w for %s until %s", ErrCircuitOpen, key, b.openUntil.Format("15:04:05"))
+		}
+		b.state = breakerHalfOpen
+	case breakerHalfOpen:
+		return fmt.Errorf("%w for %s until a trial request succeeds", ErrCircuitOpen, key)
+	}
+	return nil
+}
+
+func (t *retryTransport) record(ctx context.Context, key string, ok bool) {
+	if t.breakerFailures <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.breakers[key]
+	if b == nil {
+		b = new(breaker)
+		t.breakers[key] = b
+	}
+
+	switch b.state {
+	case breakerHalfOpen:
+		if ok {
+			b.state = breakerClosed
+			b.failures = 0
+			logging.Info(ctx, "circuit breaker closed", "endpoint", key)
+			return
+		}
+		b.state = breakerOpen
+		b.openUntil = time.Now().Add(t.breakerCooldown)
+		logging.Warn(ctx, "circuit breaker open again, trial request failed", "endpoint", key, "cooldown", t.breakerCooldown)
+	case breakerClosed:
+		if ok {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= t.breakerFailures {
+			b.state = breakerOpen
+			b.openUntil = time.Now().Add(t.breakerCooldown)
+			b.failures = 0
+			logging.Warn(ctx, "circuit breaker open", "endpoint", key, "failures", t.breakerFailures, "cooldown", t.breakerCooldown)
+		}
+	}
+	// requests that were sent before the breaker opened do not count
+}
+
+// abandon gives up a trial request that was cancelled before it had a
+// result, so that the next request can try instead.
+func (t *retryTransport) abandon(key string) {
+	if t.breakerFailures <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if b := t.breakers[key]; b != nil && b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,281 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers with the given status codes in order, repeating the
+// last one.
+type scriptedServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests int
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		s.requests++
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// script replaces the statuses still to come.
+func (s *scriptedServer) script(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses = statuses
+}
+
+func (s *scriptedServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func roundTrip(t *testing.T, rt http.RoundTripper, url string) (int, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestRetryStatuses(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatus   int
+		wantRequests int
+	}{
+		{"success", []int{200}, 200, 1},
+		{"server error then success", []int{503, 200}, 200, 2},
+		{"two server errors then success", []int{500, 502, 200}, 200, 3},
+		{"too many requests then success", []int{429, 200}, 200, 2},
+		{"retries exhausted", []int{503}, 503, 3},
+		{"no retry on not found", []int{404, 200}, 404, 1},
+		{"no retry on unauthorized", []int{401, 200}, 401, 1},
+		{"no retry on bad request", []int{400, 200}, 400, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, tt.statuses...)
+			cfg := Config{Retries: 2, RetryBackoff: time.Millisecond}
+			rt := cfg.retryTransport(http.DefaultTransport)
+
+			status, err := roundTrip(t, rt, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := server.count(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	server := newScriptedServer(t, 200)
+	url := server.URL
+	server.Close()
+
+	cfg := Config{Retries: 2, RetryBackoff: time.Millisecond}
+	if _, err := roundTrip(t, cfg.retryTransport(http.DefaultTransport), url); err == nil {
+		t.Fatal("RoundTrip to a closed server succeeded")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cfg := Config{RetryBackoff: 100 * time.Millisecond}
+	rt := cfg.retryTransport(http.DefaultTransport)
+
+	tests := []struct {
+		attempt int
+		// the delay before jitter, which takes off up to half
+		base time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		// capped at ten times the first backoff
+		{4, time.Second},
+		{10, time.Second},
+		// large shifts overflow
+		{70, time.Second},
+	}
+
+	for _, tt := range tests {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			delay := rt.delay(tt.attempt)
+			if delay < tt.base/2 || delay > tt.base {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", tt.attempt, delay, tt.base/2, tt.base)
+			}
+			seen[delay] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("delay(%d) is always %v, want jitter", tt.attempt, rt.delay(tt.attempt))
+		}
+	}
+}
+
+func TestRetryWaitsForBackoff(t *testing.T) {
+	server := newScriptedServer(t, 503, 503, 200)
+	cfg := Config{Retries: 2, RetryBackoff: 20 * time.Millisecond}
+
+	start := time.Now()
+	if _, err := roundTrip(t, cfg.retryTransport(http.DefaultTransport), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	// at least half of 20ms and 40ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("two retries took %v, want at least 30ms of backoff", elapsed)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	server := newScriptedServer(t, 503)
+	cfg := Config{Retries: 5, RetryBackoff: time.Hour}
+	rt := cfg.retryTransport(http.DefaultTransport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RoundTrip = %v, want the context's error", err)
+	}
+	if got := server.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 200 * time.Millisecond
+	server := newScriptedServer(t, 500)
+	cfg := Config{BreakerFailures: 2, BreakerCooldown: cooldown}
+	rt := cfg.retryTransport(http.DefaultTransport)
+
+	steps := []struct {
+		name string
+		// statuses the server answers from now on, if any
+		script []int
+		// sleep before the request
+		wait         time.Duration
+		wantOpen     bool
+		wantRequests int
+	}{
+		{name: "first failure", wantRequests: 1},
+		{name: "second failure opens", wantRequests: 2},
+		{name: "open", wantOpen: true, wantRequests: 2},
+		{name: "failed trial", wait: cooldown + 50*time.Millisecond, wantRequests: 3},
+		{name: "open again after one failure", wantOpen: true, wantRequests: 3},
+		{name: "successful trial", script: []int{200}, wait: cooldown + 50*time.Millisecond, wantRequests: 4},
+		{name: "closed", script: []int{500}, wantRequests: 5},
+		{name: "failures count from zero", wantRequests: 6},
+		{name: "open after two failures", wantOpen: true, wantRequests: 6},
+	}
+
+	for _, step := range steps {
+		if step.script != nil {
+			server.script(step.script...)
+		}
+		time.Sleep(step.wait)
+
+		_, err := roundTrip(t, rt, server.URL)
+		if open := errors.Is(err, ErrCircuitOpen); open != step.wantOpen {
+			t.Fatalf("%s: RoundTrip = %v, want open %v", step.name, err, step.wantOpen)
+		}
+		if got := server.count(); got != step.wantRequests {
+			t.Fatalf("%s: requests = %d, want %d", step.name, got, step.wantRequests)
+		}
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 1)
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(500)
+			return
+		}
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	cfg := Config{BreakerFailures: 1, BreakerCooldown: cooldown}
+	rt := cfg.retryTransport(http.DefaultTransport)
+	if _, err := roundTrip(t, rt, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	fail = false
+	time.Sleep(cooldown + 20*time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := roundTrip(t, rt, server.URL)
+		done <- err
+	}()
+	<-arrived
+
+	if _, err := roundTrip(t, rt, server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request during the trial = %v, want %v", err, ErrCircuitOpen)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("trial: %v", err)
+	}
+	if _, err := roundTrip(t, rt, server.URL); err != nil {
+		t.Errorf("request after the trial = %v, want it closed", err)
+	}
+}
+
+func TestBreakerKeyedByEndpoint(t *testing.T) {
+	failing := newScriptedServer(t, 500)
+	healthy := newScriptedServer(t, 200)
+	cfg := Config{BreakerFailures: 1, BreakerCooldown: time.Minute}
+	rt := cfg.retryTransport(http.DefaultTransport)
+
+	roundTrip(t, rt, failing.URL)
+	if _, err := roundTrip(t, rt, failing.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("failing endpoint = %v, want %v", err, ErrCircuitOpen)
+	}
+	if _, err := roundTrip(t, rt, healthy.URL); err != nil {
+		t.Errorf("other endpoint = %v, want it unaffected", err)
+	}
+}
@@ -0,0 +1,233 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Kinds of schema drift.
+const (
+	// the document has a field that the Go struct does not know about
+	DriftUnknown = "unknown"
+	// the Go struct expects a field that the document does not have, so it
+	// silently stays zero
+	DriftMissing = "missing"
+)
+
+// IgnoredFields is implemented by document types that deliberately leave out
+// some of the fields the server sends, so those are not reported as drift.
+type IgnoredFields interface {
+	IgnoredFields() []string
+}
+
+// Drift is one field that differs between a document and its Go struct.
+type Drift struct {
+	// JSON path of the field, e.g. $.teams[*].services[*].flagstores
+	Field string
+	Kind  string
+}
+
+func (d Drift) String() string {
+	return d.Kind + " field " + d.Field
+}
+
+var (
+	strictSchema bool
+
+	driftMu   sync.Mutex
+	drift     = make(map[string][]Drift)
+	driftSeen = make(map[string]bool)
+	driftOnce sync.Once
+)
+
+// SetStrictSchema makes documents with unknown or missing fields fail to load
+// with ErrSchemaDrift instead of only being reported.
+func SetStrictSchema(strict bool) {
+	strictSchema = strict
+}
+
+// CheckSchema compares the fields of a JSON document with the struct it is
+// unmarshalled into. Only fields with a json tag are expected, and fields
+// tagged `schema:"optional"` may be left out. omitempty only affects how a
+// field is encoded, so it does not make a field optional.
+func CheckSchema(data []byte, out interface{}) ([]Drift, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	found := make(map[Drift]bool)
+	v := reflect.ValueOf(out)
+	walkSchema(v.Type(), v, raw, "$", found)
+
+	result := make([]Drift, 0, len(found))
+	for d := range found {
+		result = append(result, d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Field != result[j].Field {
+			return result[i].Field < result[j].Field
+		}
+		return result[i].Kind < result[j].Kind
+	})
+	return result, nil
+}
+
+// walkSchema descends into t and raw side by side. v is the value behind t
+// where there is one, so that documents unpacked into interface{} fields, like
+// API envelopes, are checked against the concrete type inside.
+func walkSchema(t reflect.Type, v reflect.Value, raw interface{}, field string, found map[Drift]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if v.IsValid() && !v.IsNil() {
+			v = v.Elem()
+		} else {
+			v = reflect.Value{}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsValid() && !v.IsNil() {
+			walkSchema(v.Elem().Type(), v.Elem(), raw, field, found)
+		}
+
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		known := make(map[string]bool)
+		if ignored, ok := reflect.Zero(t).Interface().(IgnoredFields); ok {
+			for _, name := range ignored.IgnoredFields() {
+				known[name] = true
+			}
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag, ok := sf.Tag.Lookup("json")
+			if !ok || tag == "-" || !sf.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			known[name] = true
+
+			value, present := object[name]
+			if !present {
+				if sf.Tag.Get("schema") != "optional" {
+					found[Drift{Field: field + "." + name, Kind: DriftMissing}] = true
+				}
+				continue
+			}
+
+			var fv reflect.Value
+			if v.IsValid() {
+				fv = v.Field(i)
+			}
+			walkSchema(sf.Type, fv, value, field+"."+name, found)
+		}
+
+		for name := range object {
+			if !known[name] {
+				found[Drift{Field: field + "." + name, Kind: DriftUnknown}] = true
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		array, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range array {
+			walkSchema(t.Elem(), reflect.Value{}, item, field+"[*]", found)
+		}
+
+	case reflect.Map:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, item := range object {
+			walkSchema(t.Elem(), reflect.Value{}, item, field+".*", found)
+		}
+	}
+}
+
+// checkSchema reports the drift of a fetched document. The first time a
+// field drifts, a warning is logged. In strict mode drift is an error.
+func checkSchema(ctx context.Context, u *url.URL, statusCode int, data []byte, out interface{}) error {
+	found, err := CheckSchema(data, out)
+	if err != nil {
+		// already reported by json.Unmarshal
+		return nil
+	}
+
+	document := path.Base(Endpoint(u))
+
+	driftMu.Lock()
+	drift[document] = found
+	for _, d := range found {
+		key := document + " " + d.String()
+		if !driftSeen[key] {
+			driftSeen[key] = true
+			logging.Warn(ctx, "schema drift", "document", document, "field", d.Field, "kind", d.Kind)
+		}
+	}
+	driftMu.Unlock()
+
+	if strictSchema && len(found) > 0 {
+		descriptions := make([]string, len(found))
+		for i, d := range found {
+			descriptions[i] = d.String()
+		}
+		return &FetchError{
+			Kind:       ErrSchemaDrift,
+			URL:        u.String(),
+			StatusCode: statusCode,
+			Reason:     "schema_drift",
+			Err:        fmt.Errorf("%s", strings.Join(descriptions, ", ")),
+		}
+	}
+	return nil
+}
+
+func registerSchemaDriftGauge() {
+	meter := otel.Meter(SCOPE_NAME)
+	_, err := meter.Int64ObservableGauge("scoreboard_schema_drift_fields", metric.WithDescription("1 for every field that is unknown to or missing from a fetched scoreboard document. Faceted by document, field and kind."), metric.WithInt64Callback(observeSchemaDrift))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up schema drift gauge", "error", err)
+	}
+}
+
+func observeSchemaDrift(ctx context.Context, observer metric.Int64Observer) error {
+	driftMu.Lock()
+	defer driftMu.Unlock()
+
+	for document, found := range drift {
+		for _, d := range found {
+			observer.Observe(
+				1,
+				metric.WithAttributes(
+					attribute.String("document", document),
+					attribute.String("field", d.Field),
+					attribute.String("kind", d.Kind),
+				),
+			)
+		}
+	}
+	return nil
+}
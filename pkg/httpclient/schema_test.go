@@ -0,0 +1,174 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type schemaTeam struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	Country  string          `json:"country" schema:"optional"`
+	Services []schemaService `json:"services"`
+}
+
+type schemaService struct {
+	Offense float64 `json:"offense"`
+}
+
+// schemaEncoded leaves out an empty website when encoded, but the server
+// always sends one.
+type schemaEncoded struct {
+	Name    string `json:"name"`
+	Website string `json:"website,omitempty"`
+}
+
+type schemaLenient struct {
+	Name string `json:"name"`
+}
+
+func (schemaLenient) IgnoredFields() []string {
+	return []string{"affiliation"}
+}
+
+type schemaEnvelope struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data"`
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		out  func() interface{}
+		want []Drift
+	}{
+		{
+			name: "matching",
+			doc:  `{"id": 1, "name": "alpha", "services": [{"offense": 1}]}`,
+			out:  func() interface{} { return new(schemaTeam) },
+			want: []Drift{},
+		},
+		{
+			name: "extra field",
+			doc:  `{"id": 1, "name": "alpha", "vulnbox": "10.0.0.1", "services": []}`,
+			out:  func() interface{} { return new(schemaTeam) },
+			want: []Drift{{Field: "$.vulnbox", Kind: DriftUnknown}},
+		},
+		{
+			name: "missing field",
+			doc:  `{"id": 1, "services": []}`,
+			out:  func() interface{} { return new(schemaTeam) },
+			want: []Drift{{Field: "$.name", Kind: DriftMissing}},
+		},
+		{
+			name: "missing optional field",
+			doc:  `{"id": 1, "name": "alpha", "services": []}`,
+			out:  func() interface{} { return new(schemaTeam) },
+			want: []Drift{},
+		},
+		{
+			name: "missing omitempty field",
+			doc:  `{"name": "alpha"}`,
+			out:  func() interface{} { return new(schemaEncoded) },
+			want: []Drift{{Field: "$.website", Kind: DriftMissing}},
+		},
+		{
+			name: "extra and missing in a nested array",
+			doc:  `{"id": 1, "name": "alpha", "services": [{"flagstores": 2}, {"offense": 1}]}`,
+			out:  func() interface{} { return new(schemaTeam) },
+			want: []Drift{
+				{Field: "$.services[*].flagstores", Kind: DriftUnknown},
+				{Field: "$.services[*].offense", Kind: DriftMissing},
+			},
+		},
+		{
+			name: "map values",
+			doc:  `{"1": {"id": 1, "name": "alpha", "services": []}, "2": {"id": 2, "services": [], "country": "EE"}}`,
+			out:  func() interface{} { return new(map[string]schemaTeam) },
+			want: []Drift{{Field: "$.*.name", Kind: DriftMissing}},
+		},
+		{
+			name: "ignored field",
+			doc:  `{"name": "alpha", "affiliation": "uni", "website": "x"}`,
+			out:  func() interface{} { return new(schemaLenient) },
+			want: []Drift{{Field: "$.website", Kind: DriftUnknown}},
+		},
+		{
+			name: "envelope",
+			doc:  `{"success": true, "data": [{"name": "alpha", "rank": 1}]}`,
+			out: func() interface{} {
+				return &schemaEnvelope{Data: new([]schemaLenient)}
+			},
+			want: []Drift{{Field: "$.data[*].rank", Kind: DriftUnknown}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckSchema([]byte(tt.doc), tt.out())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckSchema = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetJSONSchemaDrift(t *testing.T) {
+	tests := []struct {
+		name      string
+		doc       string
+		strict    bool
+		wantDrift bool
+	}{
+		{name: "matching", doc: `{"id": 1, "name": "alpha", "services": []}`, strict: true},
+		{name: "extra field", doc: `{"id": 1, "name": "alpha", "vulnbox": "x", "services": []}`, wantDrift: true},
+		{name: "extra field strict", doc: `{"id": 1, "name": "alpha", "vulnbox": "x", "services": []}`, strict: true, wantDrift: true},
+		{name: "missing field", doc: `{"id": 1, "services": []}`, wantDrift: true},
+		{name: "missing field strict", doc: `{"id": 1, "services": []}`, strict: true, wantDrift: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, tt.doc)
+			}))
+			defer server.Close()
+
+			SetStrictSchema(tt.strict)
+			t.Cleanup(func() { SetStrictSchema(false) })
+
+			client, err := New(Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var team schemaTeam
+			err = GetJSON(context.Background(), client, server.URL+"/scoreboard_team.json", &team)
+
+			if wantErr := tt.strict && tt.wantDrift; errors.Is(err, ErrSchemaDrift) != wantErr {
+				t.Errorf("GetJSON = %v, want schema drift error %v", err, wantErr)
+			}
+			if !tt.wantDrift && err != nil {
+				t.Errorf("GetJSON = %v", err)
+			}
+			if !tt.strict && err == nil && team.ID != 1 {
+				t.Errorf("team = %+v, want it decoded", team)
+			}
+
+			driftMu.Lock()
+			reported := len(drift["scoreboard_team.json"]) > 0
+			driftMu.Unlock()
+			if reported != tt.wantDrift {
+				t.Errorf("drift reported = %v, want %v", reported, tt.wantDrift)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+// Package logging writes levelled, structured log lines as text (logfmt) or
+// JSON. Fields are key-value pairs, like in log/slog, which needs a newer Go:
+//
+//	logging.Info(ctx, "fetched", "endpoint", endpoint, "status", 200)
+//
+// Fields attached to a context with With end up on every line logged with
+// that context. Repeated identical warnings and errors are only written once
+// per RepeatInterval.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// ParseLevel accepts debug, info, warn and error.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, use debug, info, warn or error", s)
+}
+
+// RepeatInterval is how long identical warnings and errors are held back
+// after they were logged.
+var RepeatInterval = time.Minute
+
+// how many distinct lines are remembered before old ones are forgotten
+const maxRepeats = 1000
+
+var (
+	mu       sync.Mutex
+	out      io.Writer = os.Stderr
+	minLevel           = LevelInfo
+	asJSON   bool
+	repeats  = make(map[string]*repeat)
+)
+
+type repeat struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// Setup sets the minimum level and the format, text or json.
+func Setup(level string, format string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format %q, use text or json", format)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel = l
+	asJSON = format == "json"
+	return nil
+}
+
+// SetOutput sets where log lines are written, stderr by default.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+type contextKey struct{}
+
+// With returns a context whose log lines carry the given fields.
+func With(ctx context.Context, args ...interface{}) context.Context {
+	fields, _ := ctx.Value(contextKey{}).([]interface{})
+	merged := make([]interface{}, 0, len(fields)+len(args))
+	merged = append(merged, fields...)
+	merged = append(merged, args...)
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+func Debug(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelDebug, msg, args)
+}
+
+func Info(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelInfo, msg, args)
+}
+
+func Warn(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelWarn, msg, args)
+}
+
+func Error(ctx context.Context, msg string, args ...interface{}) {
+	write(ctx, LevelError, msg, args)
+}
+
+// Enabled reports whether lines of this level are written.
+func Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return level >= minLevel
+}
+
+func write(ctx context.Context, level Level, msg string, args []interface{}) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fields, _ := ctx.Value(contextKey{}).([]interface{})
+	fields = append(append([]interface{}(nil), fields...), args...)
+	if len(fields)%2 == 1 {
+		fields = append(fields, "!MISSING")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	if level >= LevelWarn {
+		key := repeatKey(level, msg, fields)
+		r, ok := repeats[key]
+		now := time.Now()
+		if ok && now.Sub(r.loggedAt) < RepeatInterval {
+			r.suppressed++
+			return
+		}
+		if ok && r.suppressed > 0 {
+			fields = append(fields, "repeated", r.suppressed)
+		}
+		if len(repeats) >= maxRepeats {
+			for k, r := range repeats {
+				if now.Sub(r.loggedAt) >= RepeatInterval {
+					delete(repeats, k)
+				}
+			}
+		}
+		repeats[key] = &repeat{loggedAt: now}
+	}
+
+	var line []byte
+	if asJSON {
+		line = formatJSON(time.Now(), level, msg, fields)
+	} else {
+		line = formatText(time.Now(), level, msg, fields)
+	}
+	out.Write(line)
+}
+
+// repeatKey identifies identical lines. Durations differ between otherwise
+// identical failures, so they are left out.
+func repeatKey(level Level, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if _, ok := fields[i+1].(time.Duration); ok {
+			continue
+		}
+		fmt.Fprintf(&b, " %v=%s", fields[i], stringValue(fields[i+1]))
+	}
+	return b.String()
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.Round(time.Millisecond).String()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatText(now time.Time, level Level, msg string, fields []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString("time=")
+	b.WriteString(now.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(quote(msg))
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(stringValue(fields[i]))
+		b.WriteByte('=')
+		b.WriteString(quote(stringValue(fields[i+1])))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func formatJSON(now time.Time, level Level, msg string, fields []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteByte(',')
+		writeJSON(&b, stringValue(fields[i]))
+		b.WriteByte(':')
+		switch v := fields[i+1].(type) {
+		case time.Duration:
+			writeJSON(&b, v.Seconds())
+		case error, fmt.Stringer:
+			writeJSON(&b, stringValue(v))
+		default:
+			writeJSON(&b, v)
+		}
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// StdWriter turns lines written with the standard log package, e.g. by
+// libraries, into info lines. Use it with log.SetFlags(0).
+func StdWriter() io.Writer {
+	return stdWriter{}
+}
+
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	Info(context.Background(), strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
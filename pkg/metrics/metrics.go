@@ -2,26 +2,50 @@ package metrics
 
 import (
 	"context"
-	"log"
+	"fmt"
 
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/metric"
 )
 
 func Setup() (func(), error) {
 	exporter, err := prometheus.New()
 	if err != nil {
-		log.Fatalf("failed to initialize metric provider: %v", err)
+		return nil, fmt.Errorf("while setting up the prometheus exporter: %w", err)
 	}
-	provider := metric.NewMeterProvider(metric.WithReader(exporter))
+	provider := metric.NewMeterProvider(
+		metric.WithReader(exporter),
+		metric.WithView(
+			histogramView("scoreboard_exporter_fetch_duration_seconds", fetchDurationBuckets),
+			histogramView("scoreboard_exporter_response_bytes", responseBytesBuckets),
+		),
+	)
 	otel.SetMeterProvider(provider)
+	// failed callbacks are reported on every scrape, so they go through the
+	// rate limited logger
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logging.Error(context.Background(), "while collecting metrics", "error", err)
+	}))
+	// instruments cannot be created while metrics are being collected, which
+	// is when the first fetch usually happens
+	selfOnce.Do(setupSelf)
 
 	return func() {
 		ctx := context.Background()
 		err = provider.Shutdown(ctx)
 		if err != nil {
-			log.Printf("failed to clean up metrics: %v", err)
+			logging.Error(ctx, "failed to clean up metrics", "error", err)
 		}
 	}, nil
 }
+
+// histogramView sets the bucket bounds of a histogram.
+func histogramView(name string, bounds []float64) metric.View {
+	return metric.NewView(
+		metric.Instrument{Name: name, Scope: instrumentation.Scope{Name: SELF_SCOPE_NAME}},
+		metric.Stream{Aggregation: metric.AggregationExplicitBucketHistogram{Boundaries: bounds}},
+	)
+}
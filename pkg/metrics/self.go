@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// SELF_SCOPE_NAME is the scope of the metrics the exporter reports about
+// itself, as opposed to the scoreboard.
+const SELF_SCOPE_NAME string = "scoreboard_exporter"
+
+var (
+	selfOnce      sync.Once
+	fetchDuration metric.Float64Histogram
+	fetchErrors   metric.Int64Counter
+	responseBytes metric.Int64Histogram
+	cacheHits     metric.Int64Counter
+	cacheMisses   metric.Int64Counter
+	events        metric.Int64Counter
+	notifications metric.Int64Counter
+
+	lastSuccessMu sync.Mutex
+	lastSuccess   = make(map[string]time.Time)
+	lastFetch     = make(map[string]FetchResult)
+
+	skippedRowsMu sync.Mutex
+	skippedRows   = make(map[skippedRowsKey]int64)
+)
+
+// Bucket bounds of the self histograms. The SDK's default bounds are meant
+// for milliseconds and would put every fetch into the same bucket.
+var (
+	fetchDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	responseBytesBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+)
+
+type skippedRowsKey struct {
+	document string
+	reason   string
+}
+
+func setupSelf() {
+	meter := otel.Meter(SELF_SCOPE_NAME)
+	var err error
+
+	fetchDuration, err = meter.Float64Histogram("scoreboard_exporter_fetch_duration_seconds", metric.WithDescription("Time taken to fetch and decode a scoreboard document, including retries. Faceted by endpoint."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up fetch duration histogram", "error", err)
+	}
+
+	fetchErrors, err = meter.Int64Counter("scoreboard_exporter_fetch_errors_total", metric.WithDescription("Failed scoreboard fetches. Faceted by endpoint and reason."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up fetch errors counter", "error", err)
+	}
+
+	responseBytes, err = meter.Int64Histogram("scoreboard_exporter_response_bytes", metric.WithDescription("Size of fetched scoreboard documents. Faceted by endpoint."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up response size histogram", "error", err)
+	}
+
+	cacheHits, err = meter.Int64Counter("scoreboard_exporter_cache_hits_total", metric.WithDescription("Scoreboard documents served from the cache without fetching. Faceted by document."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up cache hits counter", "error", err)
+	}
+
+	cacheMisses, err = meter.Int64Counter("scoreboard_exporter_cache_misses_total", metric.WithDescription("Scoreboard documents that had to be fetched. Faceted by document."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up cache misses counter", "error", err)
+	}
+
+	events, err = meter.Int64Counter("scoreboard_events_total", metric.WithDescription("Scoreboard events seen between consecutive ticks. Faceted by kind, team and service."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up events counter", "error", err)
+	}
+
+	notifications, err = meter.Int64Counter("scoreboard_exporter_notifications_total", metric.WithDescription("Chat notifications about scoreboard events. Faceted by sink and result."))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up notifications counter", "error", err)
+	}
+
+	_, err = meter.Float64ObservableGauge("scoreboard_exporter_last_success_timestamp_seconds", metric.WithDescription("Unix time of the last successful fetch. Faceted by endpoint."), metric.WithFloat64Callback(observeLastSuccess))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up last success gauge", "error", err)
+	}
+
+	_, err = meter.Int64ObservableGauge("scoreboard_exporter_skipped_rows", metric.WithDescription("Team rows left out of the latest snapshot because they could not be mapped to services. Faceted by document and reason."), metric.WithInt64Callback(observeSkippedRows))
+	if err != nil {
+		logging.Error(context.Background(), "while setting up skipped rows gauge", "error", err)
+	}
+}
+
+// FetchResult is the outcome of the latest fetch of an endpoint.
+type FetchResult struct {
+	At       time.Time
+	Duration time.Duration
+	// error reason, empty if the fetch succeeded
+	Reason      string
+	Error       string
+	LastSuccess time.Time
+}
+
+// ObserveFetch records the outcome of fetching one document. reason is
+// ignored if the fetch succeeded.
+func ObserveFetch(ctx context.Context, endpoint string, duration time.Duration, size int, reason string, err error) {
+	selfOnce.Do(setupSelf)
+
+	attrs := metric.WithAttributes(attribute.String("endpoint", endpoint))
+	fetchDuration.Record(ctx, duration.Seconds(), attrs)
+
+	lastSuccessMu.Lock()
+	result := FetchResult{At: time.Now(), Duration: duration, Reason: reason, LastSuccess: lastSuccess[endpoint]}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Reason = ""
+		result.LastSuccess = result.At
+		lastSuccess[endpoint] = result.At
+	}
+	lastFetch[endpoint] = result
+	lastSuccessMu.Unlock()
+
+	if err != nil {
+		fetchErrors.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("endpoint", endpoint),
+			attribute.String("reason", reason),
+		))
+		return
+	}
+
+	responseBytes.Record(ctx, int64(size), attrs)
+}
+
+// LastFetches returns the latest fetch result of every endpoint.
+func LastFetches() map[string]FetchResult {
+	lastSuccessMu.Lock()
+	defer lastSuccessMu.Unlock()
+
+	results := make(map[string]FetchResult, len(lastFetch))
+	for endpoint, result := range lastFetch {
+		results[endpoint] = result
+	}
+	return results
+}
+
+// ObserveCache records whether a document was served from the cache.
+func ObserveCache(ctx context.Context, document string, hit bool) {
+	selfOnce.Do(setupSelf)
+
+	attrs := metric.WithAttributes(attribute.String("document", document))
+	if hit {
+		cacheHits.Add(ctx, 1, attrs)
+	} else {
+		cacheMisses.Add(ctx, 1, attrs)
+	}
+}
+
+// CountEvent counts a scoreboard event. team and service may be empty.
+func CountEvent(ctx context.Context, kind string, team string, service string) {
+	selfOnce.Do(setupSelf)
+
+	events.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("kind", kind),
+		attribute.String("team", team),
+		attribute.String("service", service),
+	))
+}
+
+// CountNotification counts a message to a notification sink. result is one
+// of sent, failed, rate_limited or dropped.
+func CountNotification(ctx context.Context, sink string, result string) {
+	selfOnce.Do(setupSelf)
+
+	notifications.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("sink", sink),
+		attribute.String("result", result),
+	))
+}
+
+// SetSkippedRows records how many rows of the latest snapshot of a document
+// were skipped for reason.
+func SetSkippedRows(document string, reason string, count int64) {
+	skippedRowsMu.Lock()
+	defer skippedRowsMu.Unlock()
+	skippedRows[skippedRowsKey{document, reason}] = count
+}
+
+func observeLastSuccess(ctx context.Context, observer metric.Float64Observer) error {
+	lastSuccessMu.Lock()
+	defer lastSuccessMu.Unlock()
+
+	for endpoint, at := range lastSuccess {
+		observer.Observe(
+			float64(at.UnixMilli())/1000,
+			metric.WithAttributes(
+				attribute.String("endpoint", endpoint),
+			),
+		)
+	}
+	return nil
+}
+
+func observeSkippedRows(ctx context.Context, observer metric.Int64Observer) error {
+	skippedRowsMu.Lock()
+	defer skippedRowsMu.Unlock()
+
+	for key, count := range skippedRows {
+		observer.Observe(
+			count,
+			metric.WithAttributes(
+				attribute.String("document", key.document),
+				attribute.String("reason", key.reason),
+			),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes whom to notify about which events. See
+// examples/notify.yml for an annotated example.
+type Config struct {
+	// our team's name on the scoreboard. Only events about this team are
+	// sent, and events that are not about any team.
+	Team  string       `yaml:"team"`
+	Sinks []SinkConfig `yaml:"sinks"`
+}
+
+type SinkConfig struct {
+	// name in logs and metrics, defaults to the type
+	Name string `yaml:"name"`
+	// discord, slack, mattermost or webhook
+	Type string `yaml:"type"`
+	// URL of the webhook. "env:NAME" reads it from an environment variable,
+	// "file:/path" from a file.
+	URL string `yaml:"url"`
+	// kinds of events to send, see DefaultEvents
+	Events []events.Kind `yaml:"events"`
+	// new statuses of service_status_changed events to send, see
+	// DefaultStatuses. Compared without case.
+	Statuses []string `yaml:"statuses"`
+	// services to send events about, all if empty
+	Services []string `yaml:"services"`
+	// text/template of the line sent for an event, see DefaultTemplate. The
+	// events of a tick are sent as one message, one line each.
+	Template  string          `yaml:"template"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig allows at most Messages messages per Interval. Messages
+// over the limit are dropped, and the next message sent says how many.
+type RateLimitConfig struct {
+	// defaults to 5
+	Messages int `yaml:"messages"`
+	// defaults to 1m
+	Interval time.Duration `yaml:"interval"`
+}
+
+const (
+	TypeDiscord    = "discord"
+	TypeSlack      = "slack"
+	TypeMattermost = "mattermost"
+	TypeWebhook    = "webhook"
+)
+
+var (
+	// DefaultEvents are sent when a sink does not list its events: a service
+	// going down, lost flags, first blood and rank changes.
+	DefaultEvents = []events.Kind{events.ServiceStatusChanged, events.FlagsLost, events.FirstBlood, events.RankOvertake}
+	// DefaultStatuses are the service statuses sent when a sink does not
+	// list them.
+	DefaultStatuses = []string{"down", "faulty"}
+)
+
+// DefaultTemplate is the line sent for an event when a sink has no template.
+const DefaultTemplate = "tick {{.Tick}}: {{.}}"
+
+const (
+	defaultRateLimitMessages = 5
+	defaultRateLimitInterval = time.Minute
+)
+
+// LoadConfig reads and validates a YAML config file. Webhook URLs given as
+// env: or file: are resolved.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("while reading notify config: %w", err)
+	}
+
+	cfg := new(Config)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("while parsing notify config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notify config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the config and fills in the defaults.
+func (c *Config) Validate() error {
+	if c.Team == "" {
+		return fmt.Errorf("team is required")
+	}
+	if len(c.Sinks) == 0 {
+		return fmt.Errorf("at least one sink is required")
+	}
+
+	names := make(map[string]bool)
+	for idx := range c.Sinks {
+		s := &c.Sinks[idx]
+
+		switch s.Type {
+		case TypeDiscord, TypeSlack, TypeMattermost, TypeWebhook:
+		case "":
+			return fmt.Errorf("sinks[%d] needs a type: discord, slack, mattermost or webhook", idx)
+		default:
+			return fmt.Errorf("sinks[%d] has unknown type %q, use one of discord, slack, mattermost and webhook", idx, s.Type)
+		}
+		if s.Name == "" {
+			s.Name = s.Type
+		}
+		if names[s.Name] {
+			return fmt.Errorf("sinks[%d]: there is more than one sink named %q, set name to tell them apart", idx, s.Name)
+		}
+		names[s.Name] = true
+
+		if s.URL == "" {
+			return fmt.Errorf("sinks[%d] needs a url", idx)
+		}
+		resolved, err := httpclient.ReadSecret(s.URL)
+		if err != nil {
+			return fmt.Errorf("sinks[%d].url: %w", idx, err)
+		}
+		if u, err := url.Parse(resolved); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			// the URL itself may be a secret, so it is not repeated
+			return fmt.Errorf("sinks[%d].url is not an http or https URL", idx)
+		}
+		s.URL = resolved
+
+		if len(s.Events) == 0 {
+			s.Events = DefaultEvents
+		}
+		for _, kind := range s.Events {
+			if !kind.Valid() {
+				return fmt.Errorf("sinks[%d] has unknown event %q", idx, kind)
+			}
+		}
+		if len(s.Statuses) == 0 {
+			s.Statuses = DefaultStatuses
+		}
+
+		if s.Template == "" {
+			s.Template = DefaultTemplate
+		}
+		if _, err := template.New(s.Name).Parse(s.Template); err != nil {
+			return fmt.Errorf("sinks[%d].template: %w", idx, err)
+		}
+
+		if s.RateLimit.Messages < 0 || s.RateLimit.Interval < 0 {
+			return fmt.Errorf("sinks[%d].rate_limit must not be negative", idx)
+		}
+		if s.RateLimit.Messages == 0 {
+			s.RateLimit.Messages = defaultRateLimitMessages
+		}
+		if s.RateLimit.Interval == 0 {
+			s.RateLimit.Interval = defaultRateLimitInterval
+		}
+	}
+
+	return nil
+}
+
+// wants reports whether the sink sends an event about our team.
+func (s *SinkConfig) wants(event events.Event) bool {
+	kind := false
+	for _, k := range s.Events {
+		kind = kind || k == event.Kind
+	}
+	if !kind {
+		return false
+	}
+
+	if event.Kind == events.ServiceStatusChanged {
+		status := false
+		for _, st := range s.Statuses {
+			status = status || strings.EqualFold(st, event.New)
+		}
+		if !status {
+			return false
+		}
+	}
+
+	if len(s.Services) > 0 && event.Service != "" {
+		for _, service := range s.Services {
+			if service == event.Service {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
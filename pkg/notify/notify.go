@@ -0,0 +1,286 @@
+// Package notify sends scoreboard events about our own team to chat webhooks,
+// e.g. when one of our services goes down or we lose flags, so that nobody has
+// to keep an eye on the scoreboard during the game.
+//
+// The Notifier is an events.Sink. Messages are sent from a queue per sink, so
+// a slow webhook does not hold up the events engine.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+)
+
+const (
+	// how many messages may wait for a slow webhook before new ones are
+	// dropped
+	queueSize = 16
+	// how long a webhook may take to answer
+	sendTimeout = 10 * time.Second
+	// the longest Retry-After of a rate limited webhook that is waited for
+	maxRetryAfter = 30 * time.Second
+	// Discord refuses longer messages
+	discordMaxLength = 2000
+)
+
+// Notifier sends the events about our team to every configured sink.
+type Notifier struct {
+	team  string
+	sinks []*sink
+}
+
+type message struct {
+	text   string
+	events []events.Event
+}
+
+type sink struct {
+	cfg      SinkConfig
+	team     string
+	template *template.Template
+	client   *http.Client
+	queue    chan message
+
+	mu sync.Mutex
+	// when the messages within the rate limit interval were sent
+	sent []time.Time
+	// messages left out by the rate limit since the last one sent
+	suppressed int
+}
+
+// New sets up the sinks of a validated config. Call Run to start sending.
+func New(cfg *Config) (*Notifier, error) {
+	n := &Notifier{team: cfg.Team}
+	for _, sc := range cfg.Sinks {
+		tmpl, err := template.New(sc.Name).Parse(sc.Template)
+		if err != nil {
+			return nil, fmt.Errorf("template of sink %s: %w", sc.Name, err)
+		}
+		n.sinks = append(n.sinks, &sink{
+			cfg:      sc,
+			team:     cfg.Team,
+			template: tmpl,
+			client:   &http.Client{Timeout: sendTimeout},
+			queue:    make(chan message, queueSize),
+		})
+	}
+	return n, nil
+}
+
+// Handle queues the events of a tick that are about our team.
+func (n *Notifier) Handle(ctx context.Context, evs []events.Event) {
+	var ours []events.Event
+	for _, event := range evs {
+		if (event.Team == "" && event.OtherTeam == "") || event.Team == n.team || event.OtherTeam == n.team {
+			ours = append(ours, event)
+		}
+	}
+	if len(ours) == 0 {
+		return
+	}
+
+	for _, s := range n.sinks {
+		s.enqueue(ctx, ours)
+	}
+}
+
+// Run sends the queued messages until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range n.sinks {
+		wg.Add(1)
+		go func(s *sink) {
+			defer wg.Done()
+			s.run(ctx)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// Test sends a test message to every sink right away, bypassing the filters
+// and the rate limit.
+func (n *Notifier) Test(ctx context.Context) error {
+	var failed []string
+	for _, s := range n.sinks {
+		msg := message{text: fmt.Sprintf("scoreboard_exporter test notification for team %s", n.team)}
+		if err := s.send(ctx, msg); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.cfg.Name, err))
+			continue
+		}
+		logging.Info(ctx, "notify: test notification sent", "sink", s.cfg.Name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("test notification failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// enqueue renders the events the sink wants into one message and queues it,
+// unless the rate limit is reached.
+func (s *sink) enqueue(ctx context.Context, evs []events.Event) {
+	var lines []string
+	var sent []events.Event
+	for _, event := range evs {
+		if !s.cfg.wants(event) {
+			continue
+		}
+		var line bytes.Buffer
+		if err := s.template.Execute(&line, event); err != nil {
+			logging.Warn(ctx, "notify: while rendering template", "sink", s.cfg.Name, "error", err)
+			line.Reset()
+			line.WriteString(event.String())
+		}
+		// templates may leave out events by rendering nothing
+		if text := strings.TrimSpace(line.String()); text != "" {
+			lines = append(lines, text)
+			sent = append(sent, event)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	recent := s.sent[:0]
+	for _, at := range s.sent {
+		if now.Sub(at) < s.cfg.RateLimit.Interval {
+			recent = append(recent, at)
+		}
+	}
+	s.sent = recent
+	if len(s.sent) >= s.cfg.RateLimit.Messages {
+		s.suppressed++
+		s.mu.Unlock()
+		metrics.CountNotification(ctx, s.cfg.Name, "rate_limited")
+		logging.Info(ctx, "notify: rate limit reached, message left out", "sink", s.cfg.Name, "events", len(sent))
+		return
+	}
+	if s.suppressed > 0 {
+		lines = append(lines, fmt.Sprintf("(%d earlier messages were left out by the rate limit)", s.suppressed))
+		s.suppressed = 0
+	}
+	s.sent = append(s.sent, now)
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- message{text: strings.Join(lines, "\n"), events: sent}:
+	default:
+		metrics.CountNotification(ctx, s.cfg.Name, "dropped")
+		logging.Warn(ctx, "notify: queue is full, message dropped", "sink", s.cfg.Name, "events", len(sent))
+	}
+}
+
+func (s *sink) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-s.queue:
+			if err := s.send(ctx, msg); err != nil {
+				logging.Warn(ctx, "notify: while sending", "sink", s.cfg.Name, "error", err)
+			}
+		}
+	}
+}
+
+// send posts a message, waiting once for a webhook that is rate limited.
+func (s *sink) send(ctx context.Context, msg message) error {
+	body, err := s.payload(msg)
+	if err != nil {
+		metrics.CountNotification(ctx, s.cfg.Name, "failed")
+		return err
+	}
+
+	retryAfter, err := s.post(ctx, body)
+	if err != nil && retryAfter > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryAfter):
+			_, err = s.post(ctx, body)
+		}
+	}
+
+	if err != nil {
+		metrics.CountNotification(ctx, s.cfg.Name, "failed")
+		return err
+	}
+	metrics.CountNotification(ctx, s.cfg.Name, "sent")
+	return nil
+}
+
+// post sends the body once. For 429 Too Many Requests it also returns how
+// long to wait before trying again, if that is not too long.
+func (s *sink) post(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// the error includes the URL, which may be a secret
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("webhook is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("webhook answered %s", resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := time.Second
+		if seconds, perr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); perr == nil && seconds > 0 {
+			wait = time.Duration(seconds * float64(time.Second))
+		}
+		if wait <= maxRetryAfter {
+			return wait, err
+		}
+	}
+	return 0, err
+}
+
+// payload encodes a message for the sink's type.
+func (s *sink) payload(msg message) ([]byte, error) {
+	switch s.cfg.Type {
+	case TypeDiscord:
+		text := msg.text
+		if runes := []rune(text); len(runes) > discordMaxLength {
+			text = string(runes[:discordMaxLength-3]) + "..."
+		}
+		return json.Marshal(map[string]string{"content": text})
+	case TypeSlack, TypeMattermost:
+		return json.Marshal(map[string]string{"text": msg.text})
+	}
+
+	evs := msg.events
+	if evs == nil {
+		evs = []events.Event{}
+	}
+	return json.Marshal(struct {
+		Team   string         `json:"team"`
+		Text   string         `json:"text"`
+		Events []events.Event `json:"events"`
+	}{s.team, msg.text, evs})
+}
@@ -0,0 +1,358 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/events"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// webhook captures the bodies POSTed to it. respond, if set, writes the
+// answer to the nth request, counting from 0.
+type webhook struct {
+	*httptest.Server
+	bodies  chan []byte
+	respond func(w http.ResponseWriter, n int)
+
+	mu       sync.Mutex
+	requests int
+}
+
+func newWebhook(t *testing.T, respond func(w http.ResponseWriter, n int)) *webhook {
+	h := &webhook{bodies: make(chan []byte, 16), respond: respond}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		h.mu.Lock()
+		n := h.requests
+		h.requests++
+		h.mu.Unlock()
+
+		if h.respond != nil {
+			h.respond(w, n)
+		}
+		h.bodies <- body
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *webhook) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests
+}
+
+// next waits for the next body, or fails the test.
+func (h *webhook) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case body := <-h.bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message arrived")
+		return nil
+	}
+}
+
+// none checks that no further message arrives.
+func (h *webhook) none(t *testing.T) {
+	t.Helper()
+	select {
+	case body := <-h.bodies:
+		t.Errorf("unexpected message %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// start sets up and runs a notifier for team "ours" with the given sink.
+func start(t *testing.T, sc SinkConfig) *Notifier {
+	t.Helper()
+	cfg := &Config{Team: "ours", Sinks: []SinkConfig{sc}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go n.Run(ctx)
+	return n
+}
+
+func lostFlags(team string, service string, count int64) events.Event {
+	return events.Event{Kind: events.FlagsLost, Source: "faustv2", Tick: 7, Team: team, Service: service, Count: count}
+}
+
+func TestPayload(t *testing.T) {
+	tests := []struct {
+		sinkType string
+		want     map[string]interface{}
+	}{
+		{TypeDiscord, map[string]interface{}{"content": "tick 7: ours lost 3 flags on web"}},
+		{TypeSlack, map[string]interface{}{"text": "tick 7: ours lost 3 flags on web"}},
+		{TypeMattermost, map[string]interface{}{"text": "tick 7: ours lost 3 flags on web"}},
+		{TypeWebhook, map[string]interface{}{
+			"team": "ours",
+			"text": "tick 7: ours lost 3 flags on web",
+			"events": []interface{}{map[string]interface{}{
+				"kind": "flags_lost", "source": "faustv2", "tick": 7.0, "at": "0001-01-01T00:00:00Z",
+				"team": "ours", "service": "web", "count": 3.0,
+			}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sinkType, func(t *testing.T) {
+			hook := newWebhook(t, nil)
+			n := start(t, SinkConfig{Type: tt.sinkType, URL: hook.URL})
+
+			n.Handle(context.Background(), []events.Event{lostFlags("ours", "web", 3)})
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(hook.next(t), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payload = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadDiscordLength(t *testing.T) {
+	s := &sink{cfg: SinkConfig{Type: TypeDiscord}}
+	body, err := s.payload(message{text: strings.Repeat("ä", 3000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if content := []rune(got["content"]); len(content) != discordMaxLength || !strings.HasSuffix(got["content"], "...") {
+		t.Errorf("content has %d characters, want %d ending in ...", len(content), discordMaxLength)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		sink SinkConfig
+		// events of one tick
+		events []events.Event
+		// lines of the message sent, none if empty
+		want []string
+	}{
+		{
+			name:   "other teams are left out",
+			events: []events.Event{lostFlags("theirs", "web", 1), lostFlags("ours", "web", 2)},
+			want:   []string{"tick 7: ours lost 2 flags on web"},
+		},
+		{
+			name:   "nothing about us",
+			events: []events.Event{lostFlags("theirs", "web", 1)},
+		},
+		{
+			name: "overtaken by another team",
+			events: []events.Event{
+				{Kind: events.RankOvertake, Tick: 7, Team: "theirs", OtherTeam: "ours", Old: "2", New: "1"},
+				{Kind: events.RankOvertake, Tick: 7, Team: "theirs", OtherTeam: "someone", Old: "3", New: "2"},
+			},
+			want: []string{"tick 7: theirs overtook ours and is now rank 1 (was 2)"},
+		},
+		{
+			name: "events without a team",
+			sink: SinkConfig{Events: []events.Kind{events.AttackersIncreased}},
+			events: []events.Event{
+				{Kind: events.AttackersIncreased, Tick: 7, Service: "web", Old: "1", New: "2"},
+				lostFlags("ours", "web", 2),
+			},
+			want: []string{"tick 7: web is exploited by 2 teams (was 1)"},
+		},
+		{
+			name: "default statuses",
+			events: []events.Event{
+				{Kind: events.ServiceStatusChanged, Tick: 7, Team: "ours", Service: "web", Old: "up", New: "down"},
+				{Kind: events.ServiceStatusChanged, Tick: 7, Team: "ours", Service: "db", Old: "down", New: "up"},
+				{Kind: events.ServiceStatusChanged, Tick: 7, Team: "ours", Service: "api", Old: "up", New: "Faulty"},
+			},
+			want: []string{"tick 7: ours: web is down (was up)", "tick 7: ours: api is Faulty (was up)"},
+		},
+		{
+			name:   "services",
+			sink:   SinkConfig{Services: []string{"db"}},
+			events: []events.Event{lostFlags("ours", "web", 1), lostFlags("ours", "db", 2)},
+			want:   []string{"tick 7: ours lost 2 flags on db"},
+		},
+		{
+			name:   "template leaving out events",
+			sink:   SinkConfig{Template: "{{if gt .Count 1}}{{.Service}}: -{{.Count}}{{end}}"},
+			events: []events.Event{lostFlags("ours", "web", 1), lostFlags("ours", "db", 2)},
+			want:   []string{"db: -2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := newWebhook(t, nil)
+			sc := tt.sink
+			sc.Type = TypeSlack
+			sc.URL = hook.URL
+			n := start(t, sc)
+
+			n.Handle(context.Background(), tt.events)
+
+			if len(tt.want) == 0 {
+				hook.none(t)
+				return
+			}
+			var got map[string]string
+			if err := json.Unmarshal(hook.next(t), &got); err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Split(got["text"], "\n"); !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("lines = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	const interval = 300 * time.Millisecond
+	hook := newWebhook(t, nil)
+	n := start(t, SinkConfig{Type: TypeSlack, URL: hook.URL, RateLimit: RateLimitConfig{Messages: 2, Interval: interval}})
+
+	for i := int64(1); i <= 4; i++ {
+		n.Handle(context.Background(), []events.Event{lostFlags("ours", "web", i)})
+	}
+	hook.next(t)
+	hook.next(t)
+	hook.none(t)
+
+	time.Sleep(interval)
+	n.Handle(context.Background(), []events.Event{lostFlags("ours", "web", 5)})
+
+	var got map[string]string
+	if err := json.Unmarshal(hook.next(t), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := "tick 7: ours lost 5 flags on web\n(2 earlier messages were left out by the rate limit)"
+	if got["text"] != want {
+		t.Errorf("text = %q, want %q", got["text"], want)
+	}
+}
+
+func TestSendStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter, n int)
+		wantErr string
+		// requests the webhook should get
+		wantRequests int
+	}{
+		{
+			name:         "ok",
+			respond:      func(w http.ResponseWriter, n int) {},
+			wantRequests: 1,
+		},
+		{
+			name:         "no content",
+			respond:      func(w http.ResponseWriter, n int) { w.WriteHeader(http.StatusNoContent) },
+			wantRequests: 1,
+		},
+		{
+			name:         "server error",
+			respond:      func(w http.ResponseWriter, n int) { w.WriteHeader(http.StatusInternalServerError) },
+			wantErr:      "webhook answered 500 Internal Server Error",
+			wantRequests: 1,
+		},
+		{
+			name:         "bad request",
+			respond:      func(w http.ResponseWriter, n int) { w.WriteHeader(http.StatusBadRequest) },
+			wantErr:      "webhook answered 400 Bad Request",
+			wantRequests: 1,
+		},
+		{
+			name: "rate limited once",
+			respond: func(w http.ResponseWriter, n int) {
+				if n == 0 {
+					w.Header().Set("Retry-After", "0.05")
+					w.WriteHeader(http.StatusTooManyRequests)
+				}
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "rate limited twice",
+			respond: func(w http.ResponseWriter, n int) {
+				w.Header().Set("Retry-After", "0.05")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr:      "webhook answered 429 Too Many Requests",
+			wantRequests: 2,
+		},
+		{
+			name: "Retry-After too long",
+			respond: func(w http.ResponseWriter, n int) {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantErr:      "webhook answered 429 Too Many Requests",
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := newWebhook(t, tt.respond)
+			n := start(t, SinkConfig{Name: "chat", Type: TypeWebhook, URL: hook.URL})
+
+			err := n.Test(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Test = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), "chat: "+tt.wantErr)) {
+				t.Errorf("Test = %v, want %q", err, tt.wantErr)
+			}
+			if got := hook.count(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	hook := newWebhook(t, nil)
+	url := hook.URL + "/secret-token"
+	hook.Close()
+
+	n := start(t, SinkConfig{Type: TypeWebhook, URL: url})
+	err := n.Test(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "webhook is not reachable") {
+		t.Fatalf("Test = %v, want webhook is not reachable", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Test = %v, which leaks the webhook URL", err)
+	}
+}
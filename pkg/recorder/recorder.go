@@ -0,0 +1,173 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+// IndexFile is the name of the index inside the record directory. It holds
+// one IndexEntry per line, in the order the documents were fetched.
+const IndexFile = "index.jsonl"
+
+type IndexEntry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	URL       string    `json:"url"`
+	SHA256    string    `json:"sha256"`
+	Bytes     int       `json:"bytes"`
+	File      string    `json:"file"`
+}
+
+// Recorder archives every successful response body to disk, gzipped. A body
+// is only written the first time its content is seen for a URL, so polling an
+// unchanged document does not fill the disk.
+type Recorder struct {
+	dir   string
+	mu    sync.Mutex
+	seen  map[string]bool
+	index *os.File
+}
+
+// New opens (or creates) a record directory. Documents already listed in an
+// existing index are not written again.
+func New(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("while creating record dir: %w", err)
+	}
+
+	r := &Recorder{
+		dir:  dir,
+		seen: make(map[string]bool),
+	}
+
+	indexPath := filepath.Join(dir, IndexFile)
+	if existing, err := os.Open(indexPath); err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			var entry IndexEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+				r.seen[entry.URL+" "+entry.SHA256] = true
+			}
+		}
+		existing.Close()
+	}
+
+	index, err := os.OpenFile(indexPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("while opening record index: %w", err)
+	}
+	r.index = index
+
+	logging.Info(context.Background(), "recording fetched documents", "dir", dir)
+	return r, nil
+}
+
+// Wrap returns a RoundTripper that records every 200 OK response of next.
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		if err := r.Record(req.URL, data); err != nil {
+			logging.Error(req.Context(), "failed to record document", "url", req.URL.String(), "error", err)
+		}
+		return resp, nil
+	})
+}
+
+// Record writes a document to the archive unless the same content has
+// already been recorded for that URL.
+func (r *Recorder) Record(u *url.URL, data []byte) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := u.String() + " " + hash
+	if r.seen[key] {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	// the timestamp prefix keeps files sorted by fetch time, and the original
+	// file name suffix keeps the archive usable with faustv2 --replay-dir
+	base := path.Base(u.Path)
+	if base == "/" || base == "." {
+		base = "document"
+	}
+	name := fmt.Sprintf("%s-%s-%s.gz", now.Format("20060102T150405.000"), hash[:12], base)
+
+	if err := writeGzip(filepath.Join(r.dir, name), data); err != nil {
+		return err
+	}
+
+	entry, err := json.Marshal(IndexEntry{
+		FetchedAt: now,
+		URL:       u.String(),
+		SHA256:    hash,
+		Bytes:     len(data),
+		File:      name,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.index.Write(append(entry, '\n')); err != nil {
+		return fmt.Errorf("while writing record index: %w", err)
+	}
+
+	r.seen[key] = true
+	return nil
+}
+
+// Close closes the index file.
+func (r *Recorder) Close() error {
+	return r.index.Close()
+}
+
+func writeGzip(filename string, data []byte) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	zw := gzip.NewWriter(file)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("while compressing %s: %w", filename, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("while compressing %s: %w", filename, err)
+	}
+	return file.Close()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
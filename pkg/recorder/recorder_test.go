@@ -0,0 +1,224 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/replay"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// readIndex returns the entries of a record directory's index.
+func readIndex(t *testing.T, dir string) []IndexEntry {
+	t.Helper()
+	file, err := os.Open(filepath.Join(dir, IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []IndexEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry IndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("index line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// documents counts the recorded documents, without the index.
+func documents(t *testing.T, dir string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := mustParse(t, "http://gameserver/competition/scoreboard-v2/scoreboard_current.json")
+	other := mustParse(t, "http://gameserver/competition/scoreboard-v2/scoreboard_teams.json")
+	steps := []struct {
+		name string
+		u    *url.URL
+		data string
+		// documents in the archive afterwards
+		want int
+	}{
+		{"first", current, `{"scoreboard_tick": 1}`, 1},
+		{"unchanged", current, `{"scoreboard_tick": 1}`, 1},
+		{"changed", current, `{"scoreboard_tick": 2}`, 2},
+		{"same content, other URL", other, `{"scoreboard_tick": 2}`, 3},
+		{"back to an earlier content", current, `{"scoreboard_tick": 1}`, 3},
+	}
+	for _, step := range steps {
+		if err := r.Record(step.u, []byte(step.data)); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := documents(t, dir); got != step.want {
+			t.Fatalf("%s: %d documents, want %d", step.name, got, step.want)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readIndex(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("index has %d entries, want 3", len(entries))
+	}
+	first := entries[0]
+	if first.URL != current.String() || first.Bytes != len(`{"scoreboard_tick": 1}`) || len(first.SHA256) != 64 {
+		t.Errorf("first entry = %+v", first)
+	}
+	if !strings.HasSuffix(first.File, "-"+first.SHA256[:12]+"-scoreboard_current.json.gz") {
+		t.Errorf("file = %q, want <timestamp>-<hash>-scoreboard_current.json.gz", first.File)
+	}
+
+	// after a restart, the index is read back and nothing is written twice
+	r, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Record(current, []byte(`{"scoreboard_tick": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := documents(t, dir); got != 3 {
+		t.Errorf("%d documents after a restart, want 3", got)
+	}
+	if err := r.Record(current, []byte(`{"scoreboard_tick": 3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(readIndex(t, dir)); got != 4 {
+		t.Errorf("index has %d entries after a restart, want 4", got)
+	}
+}
+
+type staticTransport struct {
+	status int
+	body   string
+}
+
+func (s staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: s.status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(s.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{"ok", http.StatusOK, 1},
+		{"not found", http.StatusNotFound, 0},
+		{"server error", http.StatusInternalServerError, 0},
+		{"no content", http.StatusNoContent, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r, err := New(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			body := `{"tick": 1}`
+			client := &http.Client{Transport: r.Wrap(staticTransport{status: tt.status, body: body})}
+			resp, err := client.Get("http://gameserver/competition/scoreboard.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || string(data) != body {
+				t.Errorf("body = %q, %v, want it passed on", data, err)
+			}
+
+			if got := documents(t, dir); got != tt.want {
+				t.Errorf("%d documents recorded, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// A record directory can be played back with faustv2 --replay-dir.
+func TestReplayable(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	base := "http://gameserver/competition/scoreboard-v2/"
+	recorded := map[string]string{
+		"scoreboard_round_7.json": `{"tick": 7}`,
+		"scoreboard_round_8.json": `{"tick": 8}`,
+		"scoreboard_teams.json":   `{"1": {"name": "alpha"}}`,
+	}
+	for name, data := range recorded {
+		if err := r.Record(mustParse(t, base+name), []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a long tick, so that playback stays on round 7
+	rp, err := replay.New(dir, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rp.CurrentTick(); got != 7 {
+		t.Errorf("replay starts at %d, want 7", got)
+	}
+	for _, name := range []string{"scoreboard_round_7.json", "scoreboard_teams.json"} {
+		data, err := rp.Document(name)
+		if err != nil {
+			t.Errorf("Document(%s) = %v", name, err)
+			continue
+		}
+		if !bytes.Equal(data, []byte(recorded[name])) {
+			t.Errorf("Document(%s) = %s, want %s", name, data, recorded[name])
+		}
+	}
+}
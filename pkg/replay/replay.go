@@ -0,0 +1,203 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+// Scheme is the URL scheme replays are registered under, e.g.
+// replay://game/competition/scoreboard-v2/scoreboard_current.json
+const Scheme = "replay"
+
+// Matches both plain round files and the gzipped ones written by --record-dir
+var roundFileRegexp = regexp.MustCompile(`scoreboard_round_(\d+)\.json(\.gz)?$`)
+
+// Replay plays back a directory of recorded faustv2 scoreboard files as if
+// the game was running. Only the file name of a request is looked at, so any
+// host and directory prefix works.
+type Replay struct {
+	dir          string
+	rounds       []int64
+	roundFiles   map[int64]string
+	tickDuration time.Duration
+	speed        float64
+	startedAt    time.Time
+}
+
+// New scans dir for scoreboard_round_N.json files. Playback starts at the
+// oldest round and advances one recorded round every tickDuration/speed.
+func New(dir string, tickDuration time.Duration, speed float64) (*Replay, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("replay speed must be positive, got %v", speed)
+	}
+	if tickDuration <= 0 {
+		return nil, fmt.Errorf("replay tick duration must be positive, got %v", tickDuration)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("while reading replay dir: %w", err)
+	}
+
+	r := &Replay{
+		dir:          dir,
+		roundFiles:   make(map[int64]string),
+		tickDuration: tickDuration,
+		speed:        speed,
+	}
+	for _, entry := range entries {
+		match := roundFileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		tick, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := r.roundFiles[tick]; !ok {
+			r.rounds = append(r.rounds, tick)
+		}
+		r.roundFiles[tick] = entry.Name()
+	}
+	if len(r.rounds) == 0 {
+		return nil, fmt.Errorf("no scoreboard_round_N.json files in %s", dir)
+	}
+	sort.Slice(r.rounds, func(i, j int) bool { return r.rounds[i] < r.rounds[j] })
+	r.startedAt = time.Now()
+
+	logging.Info(context.Background(), "replaying recorded rounds", "rounds", len(r.rounds), "first_tick", r.rounds[0], "last_tick", r.rounds[len(r.rounds)-1], "dir", dir, "speed", speed)
+	return r, nil
+}
+
+// scaledTick is how long a tick lasts at the replay speed.
+func (r *Replay) scaledTick() time.Duration {
+	return time.Duration(float64(r.tickDuration) / r.speed)
+}
+
+// position returns the index into rounds being played back and when the next
+// round starts.
+func (r *Replay) position() (int, time.Time) {
+	elapsed := time.Now().Sub(r.startedAt)
+	idx := int(elapsed / r.scaledTick())
+	if idx >= len(r.rounds)-1 {
+		return len(r.rounds) - 1, time.Time{}
+	}
+	return idx, r.startedAt.Add(time.Duration(idx+1) * r.scaledTick())
+}
+
+// CurrentTick returns the round currently being played back. After the last
+// recorded round, playback stays on it.
+func (r *Replay) CurrentTick() int64 {
+	idx, _ := r.position()
+	return r.rounds[idx]
+}
+
+// Document returns the contents of a scoreboard file by name, as the
+// gameserver would serve it at this point of the replay. Rounds from the
+// future and unknown files return os.ErrNotExist.
+func (r *Replay) Document(name string) ([]byte, error) {
+	idx, nextAt := r.position()
+	tick := r.rounds[idx]
+
+	if name == "scoreboard_current.json" {
+		until := nextAt
+		if until.IsZero() {
+			until = time.Now().Add(r.scaledTick())
+		}
+		return json.Marshal(map[string]interface{}{
+			"state":              0,
+			"current_tick":       tick + 1,
+			"current_tick_until": until.Unix(),
+			"scoreboard_tick":    tick,
+		})
+	}
+
+	if match := roundFileRegexp.FindStringSubmatch(name); match != nil {
+		requested, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || requested > tick {
+			return nil, os.ErrNotExist
+		}
+		file, ok := r.roundFiles[requested]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return readFile(filepath.Join(r.dir, file))
+	}
+
+	if strings.ContainsAny(name, `/\`) || name == ".." {
+		return nil, os.ErrNotExist
+	}
+	if data, err := os.ReadFile(filepath.Join(r.dir, name)); err == nil {
+		return data, nil
+	}
+	return r.newestRecorded(name)
+}
+
+// newestRecorded finds the most recent copy of a document in a directory
+// written by --record-dir, where files are named <timestamp>-<hash>-<name>.gz.
+func (r *Replay) newestRecorded(name string) ([]byte, error) {
+	matches, err := filepath.Glob(filepath.Join(r.dir, "*-"+name+".gz"))
+	if err != nil || len(matches) == 0 {
+		return nil, os.ErrNotExist
+	}
+	sort.Strings(matches)
+	return readFile(matches[len(matches)-1])
+}
+
+// readFile reads a file, decompressing it if it is gzipped.
+func readFile(filename string) ([]byte, error) {
+	if !strings.HasSuffix(filename, ".gz") {
+		return os.ReadFile(filename)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("while decompressing %s: %w", filename, err)
+	}
+	return io.ReadAll(zr)
+}
+
+// RoundTrip lets a Replay stand in for the gameserver in an http.Client.
+func (r *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+
+	data, err := r.Document(path.Base(req.URL.Path))
+	if err != nil {
+		resp.StatusCode = http.StatusNotFound
+		resp.Status = "404 Not Found"
+		resp.Body = io.NopCloser(strings.NewReader(err.Error()))
+		return resp, nil
+	}
+
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.Header.Set("Content-Type", "application/json")
+	resp.ContentLength = int64(len(data))
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
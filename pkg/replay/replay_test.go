@@ -0,0 +1,220 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/httpclient"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func writeFile(t *testing.T, dir string, name string, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeGzip(t *testing.T, dir string, name string, data string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	writeFile(t, dir, name, buf.String())
+}
+
+func round(tick int) string {
+	data, _ := json.Marshal(map[string]interface{}{"tick": tick, "scoreboard": []interface{}{}})
+	return string(data)
+}
+
+// gameDir holds rounds 3 to 5, as plain files, gzipped, and recorded by
+// --record-dir, and two recordings of the team list.
+func gameDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeFile(t, dir, "scoreboard_round_3.json", round(3))
+	writeGzip(t, dir, "20231009T120000.000-0123456789ab-scoreboard_round_4.json.gz", round(4))
+	writeGzip(t, dir, "scoreboard_round_5.json.gz", round(5))
+	writeGzip(t, dir, "20231009T120000.000-0123456789ab-scoreboard_teams.json.gz", `{"1": "old"}`)
+	writeGzip(t, dir, "20231009T130000.000-ba9876543210-scoreboard_teams.json.gz", `{"1": "new"}`)
+	writeFile(t, dir, "index.jsonl", "")
+	writeFile(t, dir, "scoreboard_round_x.json", "{}")
+	if err := os.Mkdir(filepath.Join(dir, "scoreboard_round_9.json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestNew(t *testing.T) {
+	r, err := New(gameDir(t), time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{3, 4, 5}; !reflect.DeepEqual(r.rounds, want) {
+		t.Errorf("rounds = %v, want %v", r.rounds, want)
+	}
+
+	tests := []struct {
+		name    string
+		speed   float64
+		tick    time.Duration
+		wantErr bool
+	}{
+		{name: "zero speed", speed: 0, tick: time.Minute, wantErr: true},
+		{name: "negative speed", speed: -1, tick: time.Minute, wantErr: true},
+		{name: "zero tick", speed: 1, tick: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(gameDir(t), tt.tick, tt.speed); (err != nil) != tt.wantErr {
+				t.Errorf("New = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := New(t.TempDir(), time.Minute, 1); err == nil {
+		t.Error("New on a directory without rounds succeeded")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	// a recorded minute plays back in 100ms
+	r, err := New(gameDir(t), time.Minute, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+
+	tests := []struct {
+		elapsed time.Duration
+		want    int64
+	}{
+		{0, 3},
+		{50 * time.Millisecond, 3},
+		{150 * time.Millisecond, 4},
+		{250 * time.Millisecond, 5},
+		// playback stays on the last round
+		{time.Hour, 5},
+	}
+
+	for _, tt := range tests {
+		r.startedAt = start.Add(-tt.elapsed)
+		if got := r.CurrentTick(); got != tt.want {
+			t.Errorf("after %v: tick = %d, want %d", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	r, err := New(gameDir(t), time.Minute, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// in the middle of round 4
+	r.startedAt = time.Now().Add(-150 * time.Millisecond)
+
+	var current map[string]float64
+	data, err := r.Document("scoreboard_current.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		t.Fatal(err)
+	}
+	if current["scoreboard_tick"] != 4 || current["current_tick"] != 5 {
+		t.Errorf("current = %v, want scoreboard tick 4 and current tick 5", current)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"scoreboard_round_3.json", round(3)},
+		{"scoreboard_round_4.json", round(4)},
+		{"scoreboard_teams.json", `{"1": "new"}`},
+	}
+	for _, tt := range tests {
+		data, err := r.Document(tt.name)
+		if err != nil {
+			t.Errorf("Document(%s) = %v", tt.name, err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("Document(%s) = %s, want %s", tt.name, data, tt.want)
+		}
+	}
+
+	for _, name := range []string{"scoreboard_round_5.json", "scoreboard_round_1.json", "unknown.json", "..", "index.jsonl/x"} {
+		if _, err := r.Document(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Document(%s) = %v, want %v", name, err, os.ErrNotExist)
+		}
+	}
+}
+
+type roundJSON struct {
+	Tick       int64         `json:"tick"`
+	Scoreboard []interface{} `json:"scoreboard"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	r, err := New(gameDir(t), time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpclient.RegisterProtocol(Scheme, r)
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := Scheme + "://game/competition/scoreboard-v2/"
+	var data roundJSON
+	if err := httpclient.GetJSON(context.Background(), client, base+"scoreboard_round_3.json", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Tick != 3 {
+		t.Errorf("tick = %d, want 3", data.Tick)
+	}
+
+	err = httpclient.GetJSON(context.Background(), client, base+"scoreboard_round_4.json", &data)
+	if !errors.Is(err, httpclient.ErrNotFound) {
+		t.Errorf("round from the future = %v, want %v", err, httpclient.ErrNotFound)
+	}
+}
+
+// Plain round files can also be read without a replay, through file:// URLs.
+func TestFileURL(t *testing.T) {
+	dir := gameDir(t)
+	client, err := httpclient.New(httpclient.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data roundJSON
+	if err := httpclient.GetJSON(context.Background(), client, "file://"+dir+"/scoreboard_round_3.json", &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Tick != 3 {
+		t.Errorf("tick = %d, want 3", data.Tick)
+	}
+
+	err = httpclient.GetJSON(context.Background(), client, "file://"+dir+"/scoreboard_round_7.json", &data)
+	if !errors.Is(err, httpclient.ErrNotFound) {
+		t.Errorf("missing file = %v, want %v", err, httpclient.ErrNotFound)
+	}
+}
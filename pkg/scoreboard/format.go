@@ -0,0 +1,81 @@
+package scoreboard
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteTable prints one line per team with its rank, name, points and the
+// status of every service, aligned for a terminal.
+func WriteTable(w io.Writer, s *Scoreboard) error {
+	if s.Tick >= 0 {
+		fmt.Fprintf(w, "%s, tick %d\n\n", s.Source, s.Tick)
+	} else {
+		fmt.Fprintf(w, "%s\n\n", s.Source)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "RANK\tTEAM\tPOINTS")
+	for _, service := range s.Services {
+		fmt.Fprintf(tw, "\t%s", service.Name)
+	}
+	fmt.Fprintln(tw)
+
+	for _, team := range s.Teams {
+		fmt.Fprintf(tw, "%d\t%s\t%.2f", team.Rank, team.Name, team.Points)
+		for _, service := range team.Services {
+			status := service.Status
+			if status == "" {
+				status = "-"
+			}
+			fmt.Fprintf(tw, "\t%s", status)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON prints the scoreboard as indented JSON.
+func WriteJSON(w io.Writer, s *Scoreboard) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteCSV prints one row per team and service, or one row per team when the
+// backend has no services.
+func WriteCSV(w io.Writer, s *Scoreboard) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tick", "rank", "team", "points", "service", "status", "offense", "defense", "sla", "captures", "stolen"})
+
+	tick := strconv.FormatInt(s.Tick, 10)
+	for _, team := range s.Teams {
+		row := []string{tick, strconv.FormatInt(team.Rank, 10), team.Name, formatFloat(team.Points)}
+		if len(team.Services) == 0 {
+			cw.Write(append(row, "", "", "", "", "", "", ""))
+			continue
+		}
+		for _, service := range team.Services {
+			cw.Write(append(row[:4:4],
+				service.Service,
+				service.Status,
+				formatFloat(service.Offense),
+				formatFloat(service.Defense),
+				formatFloat(service.SLA),
+				strconv.FormatInt(service.Captures, 10),
+				strconv.FormatInt(service.Stolen, 10),
+			))
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
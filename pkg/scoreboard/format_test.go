@@ -0,0 +1,118 @@
+package scoreboard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// example has team names that need quoting in CSV, a status the gameserver
+// did not describe and a team without services.
+func example() *Scoreboard {
+	until := time.Date(2023, 10, 9, 12, 3, 0, 0, time.UTC)
+	return &Scoreboard{
+		Source:    "faustv2",
+		Tick:      42,
+		TickUntil: &until,
+		FetchedAt: time.Date(2023, 10, 9, 12, 2, 30, 0, time.UTC),
+		Services: []Service{
+			{Name: "web", Attackers: 2, Victims: 1, FirstBlood: []string{"Hack, Inc."}},
+			{Name: "db"},
+		},
+		Teams: []Team{
+			{
+				Rank: 1, ID: "7", Name: "Hack, Inc.", Points: 1234.5, PointsDelta: 12.25,
+				Offense: 600, Defense: -50.5, SLA: 685,
+				Services: []TeamService{
+					{Service: "web", Status: "up", StatusCode: 0, Offense: 400, OffenseDelta: 10, Defense: -20, SLA: 300, Captures: 12, CapturesDelta: 1, Stolen: 2},
+					{Service: "db", Status: "down", StatusCode: 1, Message: "timeout", Offense: 200, Defense: -30.5, SLA: 385, Captures: 5, Stolen: 3},
+				},
+			},
+			{
+				Rank: 2, ID: "3", Name: `the "quoted" team`, Points: 99.125,
+				Services: []TeamService{
+					{Service: "web", Status: "faulty", StatusCode: 2, Offense: 0, Defense: -5, SLA: 100, Stolen: 1, StolenDelta: 1},
+					{Service: "db", StatusCode: 9, SLA: 4.125},
+				},
+			},
+			{
+				Rank: 3, ID: "12", Name: "no services", Points: 0,
+				Values: map[string]float64{"solves": 0},
+			},
+		},
+	}
+}
+
+// golden compares output with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	noTicks := example()
+	noTicks.Tick = -1
+	noTicks.Source = "ctfd"
+
+	tests := []struct {
+		golden string
+		write  func(io.Writer, *Scoreboard) error
+		sb     *Scoreboard
+	}{
+		{"table.golden", WriteTable, example()},
+		{"table_no_ticks.golden", WriteTable, noTicks},
+		{"scoreboard.csv.golden", WriteCSV, example()},
+		{"scoreboard.json.golden", WriteJSON, example()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, tt.sb); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
+
+// Team names with commas and quotes read back as they were.
+func TestCSVNames(t *testing.T) {
+	sb := example()
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, sb); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("%d rows, want a header and 5 rows", len(rows))
+	}
+	for i, want := range []string{"Hack, Inc.", "Hack, Inc.", sb.Teams[1].Name, sb.Teams[1].Name, "no services"} {
+		if got := rows[i+1][2]; got != want {
+			t.Errorf("row %d team = %q, want %q", i+1, got, want)
+		}
+	}
+}
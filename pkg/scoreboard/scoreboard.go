@@ -0,0 +1,114 @@
+// Package scoreboard is a backend-neutral view of a scoreboard: teams in rank
+// order, each with one entry per service. Every exporter can turn what it has
+// fetched into a Scoreboard, so that tools built on top of it do not need to
+// know the Faust, CTFd or generic document formats.
+package scoreboard
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Source is implemented by every exporter.
+type Source interface {
+	// Scoreboard fetches the scoreboard if needed and normalises it.
+	Scoreboard(ctx context.Context) (*Scoreboard, error)
+}
+
+type Scoreboard struct {
+	// backend name, e.g. faustv2
+	Source string `json:"source"`
+	// scoreboard tick, or -1 if the backend has no ticks
+	Tick int64 `json:"tick"`
+	// when the gameserver moves on to the next tick, if known
+	TickUntil *time.Time `json:"tick_until,omitempty"`
+	// when the scoreboard was normalised
+	FetchedAt time.Time `json:"fetched_at"`
+	Services  []Service `json:"services"`
+	Teams     []Team    `json:"teams"`
+}
+
+type Service struct {
+	Name string `json:"name"`
+	// number of teams stealing flags from this service, if known
+	Attackers int64 `json:"attackers,omitempty"`
+	// number of teams losing flags on this service, if known
+	Victims int64 `json:"victims,omitempty"`
+	// names of the teams that stole the first flag, if known
+	FirstBlood []string `json:"first_blood,omitempty"`
+}
+
+type Team struct {
+	Rank   int64   `json:"rank"`
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Points float64 `json:"points"`
+	// change of points since the previous tick, if known
+	PointsDelta float64 `json:"points_delta,omitempty"`
+	Offense     float64 `json:"offense,omitempty"`
+	Defense     float64 `json:"defense,omitempty"`
+	SLA         float64 `json:"sla,omitempty"`
+	// values of generic backend metrics with scope: team, by metric name
+	Values map[string]float64 `json:"values,omitempty"`
+	// one entry per service, in the order of Scoreboard.Services
+	Services []TeamService `json:"services,omitempty"`
+}
+
+type TeamService struct {
+	Service string `json:"service"`
+	// status description, e.g. up, down or faulty
+	Status string `json:"status,omitempty"`
+	// status code as sent by the gameserver
+	StatusCode int64 `json:"status_code"`
+	// checker message, if any
+	Message       string  `json:"message,omitempty"`
+	Offense       float64 `json:"offense"`
+	OffenseDelta  float64 `json:"offense_delta,omitempty"`
+	Defense       float64 `json:"defense"`
+	DefenseDelta  float64 `json:"defense_delta,omitempty"`
+	SLA           float64 `json:"sla"`
+	SLADelta      float64 `json:"sla_delta,omitempty"`
+	Captures      int64   `json:"captures"`
+	CapturesDelta int64   `json:"captures_delta,omitempty"`
+	Stolen        int64   `json:"stolen"`
+	StolenDelta   int64   `json:"stolen_delta,omitempty"`
+	// values of generic backend metrics with scope: service, by metric name
+	Values map[string]float64 `json:"values,omitempty"`
+}
+
+// StatusName returns the description of a status code, or the code itself
+// when the gameserver did not describe it.
+func StatusName(descriptions map[int64]string, code int64) string {
+	if name, ok := descriptions[code]; ok {
+		return name
+	}
+	return strconv.FormatInt(code, 10)
+}
+
+// Team returns the team with the given name, or nil.
+func (s *Scoreboard) Team(name string) *Team {
+	for idx := range s.Teams {
+		if s.Teams[idx].Name == name {
+			return &s.Teams[idx]
+		}
+	}
+	return nil
+}
+
+// Advanced reports whether next is a later scoreboard than previous. Without
+// ticks, any change of the ranking or points counts.
+func Advanced(previous, next *Scoreboard) bool {
+	if previous.Tick >= 0 || next.Tick >= 0 {
+		return next.Tick != previous.Tick
+	}
+	if len(previous.Teams) != len(next.Teams) {
+		return true
+	}
+	for idx := range next.Teams {
+		if next.Teams[idx].Name != previous.Teams[idx].Name || next.Teams[idx].Points != previous.Teams[idx].Points {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// Package services maps the service columns of team rows to services.
+//
+// Scoreboards list their services once per document and then give every team
+// one column per service, in the same order. When the two disagree, e.g.
+// because they come from documents fetched at different times, a team row is
+// skipped instead of being attributed to the wrong services.
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+)
+
+// Reasons for skipping a team row.
+const (
+	// the row has a different number of services than the service list
+	ReasonCountMismatch = "service_count_mismatch"
+	// a service entry in the row has no ID
+	ReasonMissingID = "missing_service_id"
+)
+
+var (
+	reportedMu sync.Mutex
+	reported   = make(map[string]map[string]int64)
+)
+
+// Index maps service columns to service names for one snapshot of a
+// document.
+type Index struct {
+	document string
+	names    []string
+	skipped  map[string]int64
+}
+
+// New builds the index of a document's service list, in column order. With
+// no names, columns are named by their position and rows of any length fit.
+// A column without a name is named by its position too, and a name already
+// taken by an earlier column gets its position appended, so that no two
+// columns end up in the same time series.
+func New(document string, names []string) *Index {
+	unique := make([]string, len(names))
+	taken := make(map[string]bool, len(names))
+	for idx, name := range names {
+		switch {
+		case name == "":
+			name = strconv.Itoa(idx)
+		case taken[name]:
+			name = name + "_" + strconv.Itoa(idx)
+		}
+		unique[idx] = name
+		taken[name] = true
+	}
+
+	return &Index{
+		document: document,
+		names:    unique,
+		skipped: map[string]int64{
+			ReasonCountMismatch: 0,
+			ReasonMissingID:     0,
+		},
+	}
+}
+
+// Len is the number of services in the index.
+func (i *Index) Len() int {
+	return len(i.names)
+}
+
+// Name returns the name of the service in column idx.
+func (i *Index) Name(idx int) string {
+	if idx < 0 || idx >= len(i.names) {
+		return strconv.Itoa(idx)
+	}
+	return i.names[idx]
+}
+
+// Fits reports whether a team row with this many service columns can be
+// mapped onto the index. Rows that do not fit are counted as skipped.
+func (i *Index) Fits(columns int) bool {
+	if len(i.names) == 0 || columns == len(i.names) {
+		return true
+	}
+	i.Skip(ReasonCountMismatch)
+	return false
+}
+
+// Skip counts a team row that was left out of the snapshot.
+func (i *Index) Skip(reason string) {
+	i.skipped[reason]++
+}
+
+// Report publishes how many rows were skipped in this snapshot, and logs
+// when that number changes.
+func (i *Index) Report(ctx context.Context) {
+	reportedMu.Lock()
+	defer reportedMu.Unlock()
+
+	previous, ok := reported[i.document]
+	if !ok {
+		previous = make(map[string]int64)
+		reported[i.document] = previous
+	}
+
+	for reason, count := range i.skipped {
+		metrics.SetSkippedRows(i.document, reason, count)
+		if count != previous[reason] {
+			logging.Warn(ctx, "skipped team rows", "document", i.document, "rows", count, "reason", reason)
+			previous[reason] = count
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"distinct", []string{"web", "db", "api"}, []string{"web", "db", "api"}},
+		{"duplicate", []string{"web", "db", "web", "web"}, []string{"web", "db", "web_2", "web_3"}},
+		{"missing", []string{"web", "", "api"}, []string{"web", "1", "api"}},
+		{"missing clashes with a name", []string{"", "0"}, []string{"0", "0_1"}},
+		{"none", nil, []string{"0", "1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := New("test", tt.names)
+			var got []string
+			for idx := range tt.want {
+				got = append(got, index.Name(idx))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := New("test", []string{"web"}).Name(-1); got != "-1" {
+		t.Errorf("Name(-1) = %q, want the position", got)
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		// service columns of each team row
+		rows        []int
+		wantFit     []bool
+		wantSkipped int64
+	}{
+		{
+			name:    "all fit",
+			names:   []string{"web", "db"},
+			rows:    []int{2, 2},
+			wantFit: []bool{true, true},
+		},
+		{
+			name:        "too few and too many",
+			names:       []string{"web", "db"},
+			rows:        []int{2, 1, 3, 0, 2},
+			wantFit:     []bool{true, false, false, false, true},
+			wantSkipped: 3,
+		},
+		{
+			name:    "no names fit any row",
+			rows:    []int{0, 1, 5},
+			wantFit: []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := New("test", tt.names)
+			var fit []bool
+			for _, columns := range tt.rows {
+				fit = append(fit, index.Fits(columns))
+			}
+			if !reflect.DeepEqual(fit, tt.wantFit) {
+				t.Errorf("fits = %v, want %v", fit, tt.wantFit)
+			}
+			if got := index.skipped[ReasonCountMismatch]; got != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", got, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+// Snapshots of a document keep the team rows that fit and report how many
+// were dropped, until a later snapshot fits again.
+func TestReport(t *testing.T) {
+	const document = "round"
+	snapshots := []struct {
+		name string
+		rows []int
+		want int64
+	}{
+		{"consistent", []int{3, 3, 3}, 0},
+		{"two rows from another round", []int{3, 2, 3, 4}, 2},
+		{"consistent again", []int{3, 3}, 0},
+	}
+
+	for _, snap := range snapshots {
+		index := New(document, []string{"web", "db", "api"})
+		kept := 0
+		for _, columns := range snap.rows {
+			if index.Fits(columns) {
+				kept++
+			}
+		}
+		index.Report(context.Background())
+
+		if want := len(snap.rows) - int(snap.want); kept != want {
+			t.Errorf("%s: kept %d rows, want %d", snap.name, kept, want)
+		}
+		reportedMu.Lock()
+		got := reported[document][ReasonCountMismatch]
+		reportedMu.Unlock()
+		if got != snap.want {
+			t.Errorf("%s: reported %d skipped rows, want %d", snap.name, got, snap.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package status serves health, readiness and a human-readable status page
+// for the configured scoreboard sources.
+package status
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/metrics"
+)
+
+// Source is a configured scoreboard backend.
+type Source interface {
+	// Status describes the source without fetching anything.
+	Status() Report
+}
+
+// Report describes a source.
+type Report struct {
+	// backend name, e.g. faustv2
+	Source string
+	URLs   []URL
+	// the cache holding the scoreboard itself, which decides readiness
+	Snapshot cache.Aged
+	// every document cache of the source, including Snapshot
+	Caches []cache.Aged
+	// current tick, or -1 if unknown
+	Tick int64
+}
+
+// URL is a configured endpoint of a source.
+type URL struct {
+	Name string
+	URL  string
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   []Source
+)
+
+// Register adds a source to the readiness check and the status page.
+func Register(source Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources = append(sources, source)
+}
+
+func registered() []Source {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	return append([]Source(nil), sources...)
+}
+
+// Healthz answers as long as the process is alive.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// ReadyHandler answers 200 if every source has a snapshot that is at most
+// maxAge old, and 503 otherwise. Only the caches are looked at: the scrapes
+// and the snapshot poller keep them fresh, so a tight probe period adds no
+// load on the gameserver.
+func ReadyHandler(maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var problems []string
+		for _, source := range registered() {
+			if err := ready(source, maxAge); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+
+		if len(problems) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(problems, "\n"))
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+}
+
+func ready(source Source, maxAge time.Duration) error {
+	report := source.Status()
+	age, ok := report.Snapshot.Age()
+	if !ok {
+		return fmt.Errorf("%s: no %s yet", report.Source, report.Snapshot.Name())
+	}
+	if age > maxAge {
+		return fmt.Errorf("%s: %s is %v old", report.Source, report.Snapshot.Name(), age.Round(time.Second))
+	}
+	return nil
+}
+
+// PageHandler serves the status page. Sources are shown as they are, without
+// fetching anything.
+func PageHandler(maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := statusPage{
+			Now:    time.Now(),
+			MaxAge: maxAge,
+		}
+
+		for _, source := range registered() {
+			report := source.Status()
+			view := sourceView{Report: report}
+			if age, ok := report.Snapshot.Age(); ok {
+				view.Ready = age <= maxAge
+			}
+			for _, c := range report.Caches {
+				cv := cacheView{Name: c.Name()}
+				cv.Age, cv.Valid = c.Age()
+				view.Caches = append(view.Caches, cv)
+			}
+			page.Sources = append(page.Sources, view)
+		}
+
+		for endpoint, result := range metrics.LastFetches() {
+			page.Fetches = append(page.Fetches, fetchView{Endpoint: endpoint, FetchResult: result})
+		}
+		sort.Slice(page.Fetches, func(i, j int) bool {
+			return page.Fetches[i].Endpoint < page.Fetches[j].Endpoint
+		})
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := pageTemplate.Execute(w, page); err != nil {
+			logging.Error(r.Context(), "while rendering status page", "error", err)
+		}
+	})
+}
+
+type statusPage struct {
+	Now     time.Time
+	MaxAge  time.Duration
+	Sources []sourceView
+	Fetches []fetchView
+}
+
+type sourceView struct {
+	Report
+	Ready  bool
+	Caches []cacheView
+}
+
+type cacheView struct {
+	Name  string
+	Age   time.Duration
+	Valid bool
+}
+
+type fetchView struct {
+	Endpoint string
+	metrics.FetchResult
+}
+
+func ago(now time.Time, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return now.Sub(t).Round(time.Millisecond).String() + " ago"
+}
+
+var pageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"ago": ago,
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Millisecond)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>scoreboard_exporter status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.ok { color: #080; }
+.fail { color: #b00; }
+</style>
+</head>
+<body>
+<h1>scoreboard_exporter</h1>
+<p>Ready means a scoreboard snapshot at most {{.MaxAge}} old. See also <a href="/metrics">/metrics</a>, <a href="/readyz">/readyz</a>.</p>
+{{range .Sources}}
+<h2>{{.Source}}</h2>
+<p>
+Tick: {{if ge .Tick 0}}{{.Tick}}{{else}}unknown{{end}}<br>
+Ready: {{if .Ready}}<span class="ok">yes</span>{{else}}<span class="fail">no</span>{{end}}
+</p>
+<table>
+<tr><th>URL</th><th></th></tr>
+{{range .URLs}}<tr><td>{{.Name}}</td><td>{{.URL}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Document</th><th>Age</th></tr>
+{{range .Caches}}<tr><td>{{.Name}}</td><td>{{if .Valid}}{{round .Age}}{{else}}<span class="fail">none</span>{{end}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No scoreboard sources are configured.</p>
+{{end}}
+<h2>Fetches</h2>
+<table>
+<tr><th>Endpoint</th><th>Last fetch</th><th>Duration</th><th>Result</th><th>Last success</th></tr>
+{{range .Fetches}}<tr><td>{{.Endpoint}}</td><td>{{ago $.Now .At}}</td><td>{{round .Duration}}</td><td>{{if .Error}}<span class="fail" title="{{.Error}}">{{.Reason}}</span>{{else}}<span class="ok">ok</span>{{end}}</td><td>{{ago $.Now .LastSuccess}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,154 @@
+package status
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/cache"
+)
+
+// fixedCache is a cache of a given age, or an empty one.
+type fixedCache struct {
+	name  string
+	age   time.Duration
+	valid bool
+}
+
+func (c fixedCache) Name() string {
+	return c.name
+}
+
+func (c fixedCache) Age() (time.Duration, bool) {
+	return c.age, c.valid
+}
+
+type fixedSource struct {
+	report Report
+}
+
+func (s fixedSource) Status() Report {
+	return s.report
+}
+
+func source(name string, snapshot fixedCache) Source {
+	return fixedSource{Report{
+		Source:   name,
+		URLs:     []URL{{Name: "round", URL: "http://gameserver/" + name}},
+		Snapshot: snapshot,
+		Caches:   []cache.Aged{snapshot, fixedCache{name: "teams"}},
+		Tick:     42,
+	}}
+}
+
+// withSources registers only the given sources for the test.
+func withSources(t *testing.T, list ...Source) {
+	sourcesMu.Lock()
+	saved := sources
+	sources = list
+	sourcesMu.Unlock()
+	t.Cleanup(func() {
+		sourcesMu.Lock()
+		sources = saved
+		sourcesMu.Unlock()
+	})
+}
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, string(body)
+}
+
+func TestReady(t *testing.T) {
+	const maxAge = time.Minute
+	fresh := fixedCache{name: "round", age: 10 * time.Second, valid: true}
+	stale := fixedCache{name: "round", age: 5 * time.Minute, valid: true}
+	never := fixedCache{name: "round"}
+
+	tests := []struct {
+		name     string
+		sources  []Source
+		wantCode int
+		wantBody []string
+	}{
+		{
+			name:     "fresh",
+			sources:  []Source{source("faustv2", fresh)},
+			wantCode: http.StatusOK,
+			wantBody: []string{"ready"},
+		},
+		{
+			name:     "stale",
+			sources:  []Source{source("faustv2", stale)},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: []string{"faustv2: round is 5m0s old"},
+		},
+		{
+			name:     "never fetched",
+			sources:  []Source{source("faustv2", never)},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: []string{"faustv2: no round yet"},
+		},
+		{
+			name:     "one of two",
+			sources:  []Source{source("faustv1", fresh), source("ctfd", never)},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: []string{"ctfd: no round yet"},
+		},
+		{
+			name:     "no sources",
+			wantCode: http.StatusOK,
+			wantBody: []string{"ready"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSources(t, tt.sources...)
+			code, body := get(t, ReadyHandler(maxAge), "/readyz")
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("body = %q, want it to contain %q", body, want)
+				}
+			}
+			if strings.Contains(body, "faustv1") {
+				t.Errorf("body = %q, mentions the ready source", body)
+			}
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	withSources(t, source("faustv2", fixedCache{name: "round"}))
+	if code, body := get(t, http.HandlerFunc(Healthz), "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("/healthz = %d %q, want 200 ok, even when not ready", code, body)
+	}
+}
+
+func TestPage(t *testing.T) {
+	withSources(t,
+		source("faustv2", fixedCache{name: "round", age: time.Second, valid: true}),
+		source("ctfd", fixedCache{name: "scoreboard"}),
+	)
+	code, body := get(t, PageHandler(time.Minute), "/status")
+	if code != http.StatusOK {
+		t.Fatalf("code = %d, want 200", code)
+	}
+	for _, want := range []string{
+		"<h2>faustv2</h2>", "Tick: 42", `<span class="ok">yes</span>`, "http://gameserver/faustv2",
+		"<h2>ctfd</h2>", `<span class="fail">no</span>`, "<td>teams</td><td><span class=\"fail\">none</span>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
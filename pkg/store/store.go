@@ -0,0 +1,160 @@
+// Package store keeps the normalised scoreboards the exporter has seen, one
+// per tick, for the dashboard and other views that need more than the
+// current scoreboard.
+package store
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/logging"
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+)
+
+// Store holds the newest scoreboard of every tick, oldest first, up to a
+// limit.
+type Store struct {
+	mu          sync.RWMutex
+	limit       int
+	history     []*scoreboard.Scoreboard
+	subscribers map[chan *scoreboard.Scoreboard]struct{}
+}
+
+// how many new ticks a slow subscriber may fall behind before the oldest
+// are dropped
+const subscriberBuffer = 4
+
+// New returns a store that keeps up to limit ticks.
+func New(limit int) *Store {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Store{
+		limit:       limit,
+		subscribers: make(map[chan *scoreboard.Scoreboard]struct{}),
+	}
+}
+
+// Add records a scoreboard. A scoreboard of the same tick as the newest one
+// replaces it, unless nothing but the fetch time changed. Add reports whether
+// sb started a new tick.
+func (s *Store) Add(sb *scoreboard.Scoreboard) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.history); n > 0 && !scoreboard.Advanced(s.history[n-1], sb) {
+		if !sameContent(s.history[n-1], sb) {
+			s.history[n-1] = sb
+		}
+		return false
+	}
+
+	s.history = append(s.history, sb)
+	if len(s.history) > s.limit {
+		s.history = append([]*scoreboard.Scoreboard(nil), s.history[len(s.history)-s.limit:]...)
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- sb:
+		default:
+			// drop the oldest tick the subscriber has not read yet
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- sb
+		}
+	}
+	return true
+}
+
+// Subscribe returns a channel that receives the scoreboard of every new tick.
+// Call the returned function to unsubscribe.
+func (s *Store) Subscribe() (<-chan *scoreboard.Scoreboard, func()) {
+	ch := make(chan *scoreboard.Scoreboard, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// sameContent reports whether two scoreboards only differ in when they were
+// fetched. The older one is kept then, so that its ETag stays the same.
+func sameContent(a, b *scoreboard.Scoreboard) bool {
+	copied := *b
+	copied.FetchedAt = a.FetchedAt
+	return reflect.DeepEqual(a, &copied)
+}
+
+// Latest returns the newest scoreboard, or nil before the first one.
+func (s *Store) Latest() *scoreboard.Scoreboard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.history) == 0 {
+		return nil
+	}
+	return s.history[len(s.history)-1]
+}
+
+// Previous returns the scoreboard of the tick before the newest one, or nil.
+func (s *Store) Previous() *scoreboard.Scoreboard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.history) < 2 {
+		return nil
+	}
+	return s.history[len(s.history)-2]
+}
+
+// History returns up to n of the newest scoreboards, oldest first.
+func (s *Store) History(n int) []*scoreboard.Scoreboard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n <= 0 || n > len(s.history) {
+		n = len(s.history)
+	}
+	return append([]*scoreboard.Scoreboard(nil), s.history[len(s.history)-n:]...)
+}
+
+// Tick returns the scoreboard of a tick, if it is still kept.
+func (s *Store) Tick(tick int64) (*scoreboard.Scoreboard, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for idx := len(s.history) - 1; idx >= 0; idx-- {
+		if s.history[idx].Tick == tick {
+			return s.history[idx], true
+		}
+	}
+	return nil, false
+}
+
+// Poll adds the scoreboard of source to the store every interval, until ctx
+// is cancelled. Failed fetches are already logged by the HTTP client.
+func Poll(ctx context.Context, source scoreboard.Source, s *Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sb, err := source.Scoreboard(ctx)
+		if err == nil {
+			if s.Add(sb) {
+				logging.Debug(ctx, "new scoreboard tick", "tick", sb.Tick)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package watch shows a live scoreboard in a terminal. It only writes ANSI
+// escape codes, so it works over SSH and in any terminal multiplexer.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/boxmein/adctf_scoreboard_exporter/pkg/scoreboard"
+)
+
+type Options struct {
+	// name of the team to highlight, if any
+	Team string
+	// how often the scoreboard is fetched
+	Interval time.Duration
+	// whether to colour service statuses and deltas
+	Color bool
+}
+
+// ANSI escape codes
+const (
+	enterAltScreen = "\x1b[?1049h"
+	leaveAltScreen = "\x1b[?1049l"
+	hideCursor     = "\x1b[?25l"
+	showCursor     = "\x1b[?25h"
+	cursorHome     = "\x1b[H"
+	clearLine      = "\x1b[K"
+	clearBelow     = "\x1b[J"
+
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	magenta = "\x1b[35m"
+	cyan    = "\x1b[36m"
+)
+
+// statusColors colours the usual Faust status descriptions. Anything else is
+// dimmed.
+var statusColors = map[string]string{
+	"up":             green,
+	"down":           red,
+	"faulty":         yellow,
+	"flag not found": magenta,
+	"recovering":     cyan,
+}
+
+type fetchResult struct {
+	scoreboard *scoreboard.Scoreboard
+	err        error
+}
+
+// Screen is the state of the live scoreboard between redraws.
+type Screen struct {
+	opts Options
+
+	current *scoreboard.Scoreboard
+	// the scoreboard of the previous tick, for rank changes and deltas
+	previous *scoreboard.Scoreboard
+	err      error
+	errAt    time.Time
+}
+
+// Run fetches the scoreboard every Interval and redraws the screen every
+// second, until ctx is cancelled.
+func Run(ctx context.Context, w io.Writer, source scoreboard.Source, opts Options) error {
+	screen := &Screen{opts: opts}
+
+	fmt.Fprint(w, enterAltScreen+hideCursor)
+	defer fmt.Fprint(w, reset+showCursor+leaveAltScreen)
+
+	results := make(chan fetchResult, 1)
+	fetch := func() {
+		sb, err := source.Scoreboard(ctx)
+		results <- fetchResult{sb, err}
+	}
+
+	go fetch()
+	fetching := true
+
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+	lastFetch := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case result := <-results:
+			fetching = false
+			screen.Update(result.scoreboard, result.err)
+		case <-redraw.C:
+			if !fetching && time.Since(lastFetch) >= opts.Interval {
+				lastFetch = time.Now()
+				fetching = true
+				go fetch()
+			}
+		}
+
+		if _, err := w.Write(screen.Render(time.Now())); err != nil {
+			return err
+		}
+	}
+}
+
+// Update records the result of a fetch.
+func (s *Screen) Update(sb *scoreboard.Scoreboard, err error) {
+	if err != nil {
+		s.err = err
+		s.errAt = time.Now()
+		return
+	}
+	s.err = nil
+
+	if s.current != nil && scoreboard.Advanced(s.current, sb) {
+		s.previous = s.current
+	}
+	s.current = sb
+}
+
+// Render draws the whole screen.
+func (s *Screen) Render(now time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString(cursorHome)
+
+	line := func(text string) {
+		b.WriteString(text)
+		b.WriteString(s.style(reset) + clearLine + "\n")
+	}
+
+	if s.current == nil {
+		line(s.style(bold) + "waiting for the scoreboard...")
+	} else {
+		s.renderScoreboard(line, now)
+	}
+
+	if s.err != nil {
+		line("")
+		line(s.style(red) + fmt.Sprintf("fetch failed %s ago: %v", now.Sub(s.errAt).Round(time.Second), s.err))
+	}
+
+	b.WriteString(clearBelow)
+	return b.Bytes()
+}
+
+func (s *Screen) renderScoreboard(line func(string), now time.Time) {
+	sb := s.current
+
+	header := sb.Source
+	if sb.Tick >= 0 {
+		header += fmt.Sprintf("   tick %d", sb.Tick)
+	}
+	if sb.TickUntil != nil {
+		left := sb.TickUntil.Sub(now).Truncate(time.Second)
+		if left < 0 {
+			left = 0
+		}
+		header += fmt.Sprintf("   next tick in %s", left)
+	}
+	header += "   updated " + sb.FetchedAt.Format("15:04:05")
+	line(s.style(bold) + header)
+	line("")
+
+	columns := []string{"#", "", "TEAM", "POINTS", "Δ"}
+	for _, service := range sb.Services {
+		columns = append(columns, service.Name)
+	}
+
+	rows := make([][]cell, 0, len(sb.Teams))
+	for _, team := range sb.Teams {
+		rows = append(rows, s.teamRow(team))
+	}
+
+	widths := make([]int, len(columns))
+	for idx, column := range columns {
+		widths[idx] = utf8.RuneCountInString(column)
+	}
+	for _, row := range rows {
+		for idx, c := range row {
+			if idx < len(widths) && utf8.RuneCountInString(c.text) > widths[idx] {
+				widths[idx] = utf8.RuneCountInString(c.text)
+			}
+		}
+	}
+
+	var head strings.Builder
+	head.WriteString("  ")
+	for idx, column := range columns {
+		head.WriteString(pad(column, widths[idx], idx))
+		head.WriteString("  ")
+	}
+	line(s.style(bold) + head.String())
+
+	for rowIdx, row := range rows {
+		rowStyle := ""
+		marker := "  "
+		if s.opts.Team != "" && sb.Teams[rowIdx].Name == s.opts.Team {
+			rowStyle = bold
+			marker = "> "
+		}
+
+		var b strings.Builder
+		b.WriteString(s.style(rowStyle) + marker)
+		for idx, c := range row {
+			if idx >= len(widths) {
+				break
+			}
+			text := pad(c.text, widths[idx], idx)
+			if c.color != "" {
+				text = s.style(c.color) + text + s.style(reset) + s.style(rowStyle)
+			}
+			b.WriteString(text)
+			b.WriteString("  ")
+		}
+		line(b.String())
+	}
+}
+
+type cell struct {
+	text  string
+	color string
+}
+
+func (s *Screen) teamRow(team scoreboard.Team) []cell {
+	delta := team.PointsDelta
+	rankChange := int64(0)
+	if s.previous != nil {
+		if previous := s.previous.Team(team.Name); previous != nil {
+			delta = team.Points - previous.Points
+			rankChange = previous.Rank - team.Rank
+		}
+	}
+
+	row := []cell{
+		{text: fmt.Sprint(team.Rank)},
+		rankCell(rankChange),
+		{text: team.Name},
+		{text: fmt.Sprintf("%.1f", team.Points)},
+		deltaCell(delta),
+	}
+
+	for _, service := range team.Services {
+		c := cell{text: service.Status, color: dim}
+		if color, ok := statusColors[strings.ToLower(service.Status)]; ok {
+			c.color = color
+		}
+		if service.StolenDelta > 0 {
+			c.text += fmt.Sprintf(" -%d", service.StolenDelta)
+		}
+		if c.text == "" {
+			c.text = "-"
+		}
+		row = append(row, c)
+	}
+	return row
+}
+
+func rankCell(change int64) cell {
+	switch {
+	case change > 0:
+		return cell{text: fmt.Sprintf("▲%d", change), color: green}
+	case change < 0:
+		return cell{text: fmt.Sprintf("▼%d", -change), color: red}
+	}
+	return cell{}
+}
+
+func deltaCell(delta float64) cell {
+	switch {
+	case delta > 0:
+		return cell{text: fmt.Sprintf("+%.1f", delta), color: green}
+	case delta < 0:
+		return cell{text: fmt.Sprintf("%.1f", delta), color: red}
+	}
+	return cell{}
+}
+
+// pad aligns numbers (the rank, points and delta columns) to the right and
+// everything else to the left.
+func pad(text string, width int, column int) string {
+	fill := strings.Repeat(" ", width-utf8.RuneCountInString(text))
+	if column == 0 || column == 3 || column == 4 {
+		return fill + text
+	}
+	return text + fill
+}
+
+func (s *Screen) style(code string) string {
+	if !s.opts.Color {
+		return ""
+	}
+	return code
+}